@@ -121,25 +121,21 @@ func tweetWorker(ctx context.Context, myStats *Stats, mbClient *textbox.Client,
 
 func main() {
 
-	// Create a new HTTP client.
-	var connLock sync.Mutex
-	var conn net.Conn
+	// Create a new HTTP client. Setting up the connection times out, but reading
+	// the stream doesn't.
 	client := &http.Client{
 		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				connLock.Lock()
-				defer connLock.Unlock()
-				if conn != nil {
-					conn.Close()
-					conn = nil
-				}
-				netc, err := net.DialTimeout(netw, addr, 5*time.Second)
-				if err != nil {
-					return nil, err
-				}
-				conn = netc
-				return netc, nil
-			},
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   2,
+			MaxConnsPerHost:       2,
+			IdleConnTimeout:       90 * time.Second,
 		},
 	}
 
@@ -8,7 +8,6 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/garyburd/go-oauth/oauth"
@@ -37,29 +36,22 @@ func NewTweetReader(consumerKey, consumerSecret, accessToken, accessSecret strin
 
 func main() {
 
-	// Create a new HTTP client. Because we are going to do some streaming analysis
-	// and may be utilizing this client from mutliple goroutines, I have borrowed
-	// the HTTP client configuration from a similar MachineBox project that will keep
-	// us safe in these scenarios. It includes both a Mutex for accessing the client
-	// and some timeout functionality.
-	var connLock sync.Mutex
-	var conn net.Conn
+	// Create a new HTTP client for the Twitter stream. It times out connecting
+	// to Twitter and waiting for an answer, but has no overall timeout, as that
+	// would cut the stream off while we are still printing tweets.
 	client := &http.Client{
 		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				connLock.Lock()
-				defer connLock.Unlock()
-				if conn != nil {
-					conn.Close()
-					conn = nil
-				}
-				netc, err := net.DialTimeout(netw, addr, 5*time.Second)
-				if err != nil {
-					return nil, err
-				}
-				conn = netc
-				return netc, nil
-			},
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   2,
+			MaxConnsPerHost:       2,
+			IdleConnTimeout:       90 * time.Second,
 		},
 	}
 
@@ -9,7 +9,6 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/garyburd/go-oauth/oauth"
@@ -38,25 +37,21 @@ func NewTweetReader(consumerKey, consumerSecret, accessToken, accessSecret strin
 
 func main() {
 
-	// Create a new HTTP client.
-	var connLock sync.Mutex
-	var conn net.Conn
+	// Create a new HTTP client. The stream is read from a goroutine below for as
+	// long as it runs, so only connecting and waiting for Twitter time out.
 	client := &http.Client{
 		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				connLock.Lock()
-				defer connLock.Unlock()
-				if conn != nil {
-					conn.Close()
-					conn = nil
-				}
-				netc, err := net.DialTimeout(netw, addr, 5*time.Second)
-				if err != nil {
-					return nil, err
-				}
-				conn = netc
-				return netc, nil
-			},
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   2,
+			MaxConnsPerHost:       2,
+			IdleConnTimeout:       90 * time.Second,
 		},
 	}
 
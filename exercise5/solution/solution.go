@@ -88,25 +88,21 @@ func (s *Stats) UpdateSentiment(newSentiment float64) {
 
 func main() {
 
-	// Create a new HTTP client.
-	var connLock sync.Mutex
-	var conn net.Conn
+	// Create a new HTTP client for the Twitter stream. The tweets are analyzed
+	// while the stream stays open, so only the connection setup times out.
 	client := &http.Client{
 		Transport: &http.Transport{
-			Dial: func(netw, addr string) (net.Conn, error) {
-				connLock.Lock()
-				defer connLock.Unlock()
-				if conn != nil {
-					conn.Close()
-					conn = nil
-				}
-				netc, err := net.DialTimeout(netw, addr, 5*time.Second)
-				if err != nil {
-					return nil, err
-				}
-				conn = netc
-				return netc, nil
-			},
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   5 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 30 * time.Second,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   2,
+			MaxConnsPerHost:       2,
+			IdleConnTimeout:       90 * time.Second,
 		},
 	}
 
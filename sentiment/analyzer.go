@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+// Analyzer analyzes the sentiment of a piece of text.
+type Analyzer interface {
+	Analyze(ctx context.Context, text string) (*textbox.Analysis, error)
+}
+
+// MachineBoxAnalyzer analyzes text with a MachineBox textbox.
+type MachineBoxAnalyzer struct {
+	Client *textbox.Client
+}
+
+// NewMachineBoxAnalyzer creates a new MachineBoxAnalyzer for the textbox
+// running at the given address, allowing up to maxConns calls at once. The
+// client's own timeout bounds how long an abandoned call can keep running
+// in the background.
+func NewMachineBoxAnalyzer(addr string, maxConns int) *MachineBoxAnalyzer {
+	client := textbox.New(addr)
+	client.HTTPClient = NewClient(30*time.Second, maxConns)
+	return &MachineBoxAnalyzer{
+		Client: client,
+	}
+}
+
+// Analyze checks the text with MachineBox. The textbox client does not take
+// a context, so the call runs in its own goroutine and Analyze returns as
+// soon as the context is done, even if MachineBox is still hung.
+func (m *MachineBoxAnalyzer) Analyze(ctx context.Context, text string) (*textbox.Analysis, error) {
+
+	type result struct {
+		analysis *textbox.Analysis
+		err      error
+	}
+
+	// Buffer the result so the goroutine can always exit.
+	done := make(chan result, 1)
+	go func() {
+		analysis, err := m.Client.Check(strings.NewReader(text))
+		done <- result{analysis, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.analysis, r.err
+	}
+}
+
+// Sentiment returns the average sentiment of the sentences in an analysis.
+func Sentiment(analysis *textbox.Analysis) float64 {
+	if len(analysis.Sentences) == 0 {
+		return 0.5
+	}
+	sentimentTotal := 0.0
+	for _, sentence := range analysis.Sentences {
+		sentimentTotal += sentence.Sentiment
+	}
+	return sentimentTotal / float64(len(analysis.Sentences))
+}
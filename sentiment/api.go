@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API serves the live stats as JSON, so other services and dashboards can
+// poll the running analyzer.
+type API struct {
+	Stats   *ShardedStats
+	Windows *WindowedStats
+	Recent  *RecentTweets
+	Trends  *Trends
+	Feed    *Feed
+	Metrics http.Handler
+
+	// Filter is the stream filter the admin endpoints change, and
+	// AdminToken the bearer token they need.
+	Filter     *LiveFilter
+	AdminToken string
+}
+
+// TermStats is the sentiment towards a term and the emoji used most with
+// it. Tracked is false for terms that are no longer tracked, whose stats
+// are kept.
+type TermStats struct {
+	Breakdown
+	TopEmoji []EmojiCount `json:"top_emoji"`
+	Tracked  bool         `json:"tracked"`
+}
+
+// Handler returns the API's routes:
+//
+//	GET /api/stats            the current snapshot of the stats
+//	GET /api/terms            the stats for every tracked term
+//	GET /api/terms/{term}     the stats for one term
+//	GET /api/window?d=5m      the stats for the last d (default 5m)
+//	GET /api/tweets?n=20      the most recent scored tweets
+//	GET /api/trends?n=10      the trending hashtags, keywords and n-grams
+//	GET /api/feed?terms=a,b   a Server-Sent Events feed of scored tweets
+//	                          and stats deltas
+//	GET /metrics              the metrics, in the Prometheus format
+//	GET /api/admin/terms      the tracked terms
+//	POST /api/admin/terms     add and remove tracked terms, with a body
+//	                          like {"add": ["a"], "remove": ["b"]}
+//	GET /                     the dashboard
+func (a *API) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", get(dashboardHandler().ServeHTTP))
+	mux.HandleFunc("/api/stats", get(a.handleStats))
+	mux.HandleFunc("/api/terms", get(a.handleTerms))
+	mux.HandleFunc("/api/terms/", get(a.handleTerm))
+	mux.HandleFunc("/api/window", get(a.handleWindow))
+	mux.HandleFunc("/api/tweets", get(a.handleTweets))
+	mux.HandleFunc("/api/trends", get(a.handleTrends))
+	mux.HandleFunc("/api/feed", get(a.Feed.ServeHTTP))
+	mux.HandleFunc("/metrics", get(a.Metrics.ServeHTTP))
+	mux.HandleFunc("/api/admin/terms", a.admin(a.handleAdminTerms))
+	return mux
+}
+
+func (a *API) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, a.Stats.Snapshot().file())
+}
+
+func (a *API) handleTerms(w http.ResponseWriter, r *http.Request) {
+	snapshot := a.Stats.Snapshot()
+	terms := make(map[string]TermStats, len(snapshot.Terms))
+	for term := range snapshot.Terms {
+		terms[term] = a.termStats(snapshot, term)
+	}
+	writeJSON(w, terms)
+}
+
+func (a *API) handleTerm(w http.ResponseWriter, r *http.Request) {
+	term := strings.TrimPrefix(r.URL.Path, "/api/terms/")
+	snapshot := a.Stats.Snapshot()
+	if _, ok := snapshot.Terms[term]; !ok {
+		http.Error(w, fmt.Sprintf("no stats for term %q", term), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, a.termStats(snapshot, term))
+}
+
+// termStats returns the stats for a term in the snapshot.
+func (a *API) termStats(s *Stats, term string) TermStats {
+	ts := TermStats{
+		Breakdown: *s.Terms[term],
+		TopEmoji:  s.TopEmoji(term, 10),
+	}
+	if a.Filter != nil {
+		filter, _ := a.Filter.Get()
+		ts.Tracked = containsFold(filter.Track, term)
+	}
+	return ts
+}
+
+// TermsUpdate is a change to the tracked terms.
+type TermsUpdate struct {
+	Add    []string `json:"add"`
+	Remove []string `json:"remove"`
+}
+
+// TrackedTerms are the terms being tracked.
+type TrackedTerms struct {
+	Terms []string `json:"terms"`
+}
+
+func (a *API) handleAdminTerms(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		filter, _ := a.Filter.Get()
+		writeJSON(w, TrackedTerms{Terms: filter.Track})
+	case http.MethodPost:
+		var u TermsUpdate
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&u); err != nil {
+			http.Error(w, fmt.Sprintf("invalid terms update: %v", err), http.StatusBadRequest)
+			return
+		}
+		filter, err := a.Filter.Update(u.Add, u.Remove)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, TrackedTerms{Terms: filter.Track})
+	default:
+		w.Header().Set("Allow", "GET, HEAD, POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) handleWindow(w http.ResponseWriter, r *http.Request) {
+	d := 5 * time.Minute
+	if v := r.URL.Query().Get("d"); v != "" {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("d must be a positive duration like 5m, not %q", v), http.StatusBadRequest)
+			return
+		}
+	}
+	if d > a.Windows.Max() {
+		http.Error(w, fmt.Sprintf("d must be at most %v", a.Windows.Max()), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, a.Windows.Window(d, time.Now()))
+}
+
+func (a *API) handleTweets(w http.ResponseWriter, r *http.Request) {
+	n, ok := queryInt(w, r, "n", 20)
+	if !ok {
+		return
+	}
+	writeJSON(w, a.Recent.Latest(n))
+}
+
+func (a *API) handleTrends(w http.ResponseWriter, r *http.Request) {
+	n, ok := queryInt(w, r, "n", 10)
+	if !ok {
+		return
+	}
+	trends := a.Trends.Trending(time.Now(), n)
+	if trends == nil {
+		trends = []Trend{}
+	}
+	writeJSON(w, trends)
+}
+
+// get wraps a handler to only allow GET (and HEAD) requests.
+func get(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// admin wraps a handler to require the admin token. Without a token set,
+// the admin endpoints are off.
+func (a *API) admin(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if a.AdminToken == "" || a.Filter == nil {
+			http.Error(w, "the admin endpoints are off; set -admin-token to turn them on", http.StatusNotFound)
+			return
+		}
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(a.AdminToken)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "invalid admin token", http.StatusUnauthorized)
+			return
+		}
+		h(w, r)
+	}
+}
+
+// queryInt reads a positive integer query parameter, writing an error and
+// returning false if it is invalid.
+func queryInt(w http.ResponseWriter, r *http.Request, name string, def int) (int, bool) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return def, true
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		http.Error(w, fmt.Sprintf("%s must be a positive integer, not %q", name, v), http.StatusBadRequest)
+		return 0, false
+	}
+	return n, true
+}
+
+// writeJSON writes v as the JSON response.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		fmt.Println("Error writing response:", err)
+	}
+}
+
+// serve serves the handler at addr until the context is done, then shuts
+// the server down. Requests get the context too, so long-lived ones like
+// the feed end with it.
+func serve(ctx context.Context, addr string, handler http.Handler) error {
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return ctx },
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return srv.Shutdown(shutdownCtx)
+}
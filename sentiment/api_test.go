@@ -0,0 +1,108 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPI(t *testing.T) {
+	a := newTestAPI()
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"stats", "GET", "/api/stats", http.StatusOK, `"total": 2`},
+		{"terms", "GET", "/api/terms", http.StatusOK, `"Russia": {`},
+		{"term", "GET", "/api/terms/Trump", http.StatusOK, `"positive": 1`},
+		{"unknown term", "GET", "/api/terms/Putin", http.StatusNotFound, `no stats for term "Putin"`},
+		{"window", "GET", "/api/window", http.StatusOK, `"total": 2`},
+		{"short window", "GET", "/api/window?d=1m", http.StatusOK, `"total": 2`},
+		{"bad window", "GET", "/api/window?d=soon", http.StatusBadRequest, "positive duration"},
+		{"negative window", "GET", "/api/window?d=-5m", http.StatusBadRequest, "positive duration"},
+		{"window too long", "GET", "/api/window?d=2h", http.StatusBadRequest, "at most 1h0m0s"},
+		{"tweets", "GET", "/api/tweets?n=1", http.StatusOK, `"text": "Russia is awful"`},
+		{"bad tweet count", "GET", "/api/tweets?n=0", http.StatusBadRequest, "positive integer"},
+		{"no trends", "GET", "/api/trends", http.StatusOK, "[]"},
+		{"bad trend count", "GET", "/api/trends?n=many", http.StatusBadRequest, "positive integer"},
+		{"post", "POST", "/api/stats", http.StatusMethodNotAllowed, "method not allowed"},
+		{"head", "HEAD", "/api/stats", http.StatusOK, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, nil))
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got body %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+			if tt.wantStatus == http.StatusOK && tt.method == "GET" {
+				if ct := w.Header().Get("Content-Type"); ct != "application/json" {
+					t.Errorf("got content type %q, want application/json", ct)
+				}
+				if !json.Valid(w.Body.Bytes()) {
+					t.Errorf("got invalid JSON %q", w.Body.String())
+				}
+			}
+		})
+	}
+}
+
+func TestAPITweetsOrder(t *testing.T) {
+	a := newTestAPI()
+	w := httptest.NewRecorder()
+	a.Handler().ServeHTTP(w, httptest.NewRequest("GET", "/api/tweets", nil))
+
+	var tweets []ScoredTweet
+	if err := json.NewDecoder(w.Body).Decode(&tweets); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, st := range tweets {
+		got = append(got, st.ID)
+	}
+	if strings.Join(got, ",") != "2,1" {
+		t.Errorf("got tweets %v, want the newest first", got)
+	}
+}
+
+// newTestAPI returns an API with the stats of two tweets, one positive
+// about Trump and one negative about Russia.
+func newTestAPI() *API {
+	a := &API{
+		Stats:   NewShardedStats(NewStats(), 1),
+		Windows: NewWindowedStats(windowBucket, windowBuckets),
+		Recent:  NewRecentTweets(10),
+		Trends:  NewTrends(5*time.Minute, time.Hour),
+		Feed:    NewFeed(),
+		Metrics: http.NotFoundHandler(),
+	}
+	for _, tw := range []struct {
+		id, text, term string
+		sentiment      float64
+	}{
+		{"1", "Trump is great", "Trump", 0.9},
+		{"2", "Russia is awful", "Russia", 0.1},
+	} {
+		var st ScoredTweet
+		st.ID = tw.id
+		st.Text = tw.text
+		st.Sentiment = tw.sentiment
+		st.Label = Label(tw.sentiment)
+		st.Weight = 1
+		st.Influence = 1
+		st.Aspects = []Aspect{{Name: tw.term, Kind: aspectTerm, Sentiment: tw.sentiment}}
+		a.Stats.Shard(0).Record(st)
+		a.Windows.Record(st, time.Now())
+		a.Recent.Add(st)
+	}
+	return a
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+// Aspect kinds.
+const (
+	aspectTerm   = "term"
+	aspectEntity = "entity"
+)
+
+// Aspect is the sentiment of a tweet towards one of the things it
+// mentions: a tracked term or a named entity found by the analyzer.
+type Aspect struct {
+	Name      string  `json:"name"`
+	Kind      string  `json:"kind"`
+	Sentiment float64 `json:"sentiment"`
+}
+
+// clauseRe matches where a clause ends: punctuation, or a conjunction
+// that often changes the subject or the sentiment.
+var clauseRe = regexp.MustCompile(`(?i)[.!?;,]+|\s+(?:but|however|although|though|whereas|while|yet)\s+`)
+
+// SplitClauses splits text into clauses, so that "love Trump, hate Russia"
+// becomes "love Trump" and "hate Russia".
+func SplitClauses(text string) []string {
+	var clauses []string
+	for _, c := range clauseRe.Split(text, -1) {
+		if c = strings.TrimSpace(c); c != "" {
+			clauses = append(clauses, c)
+		}
+	}
+	return clauses
+}
+
+// findAspects lists the tracked terms a tweet mentions and the entities
+// the analyzer found in it. Entities that are also tracked terms are only
+// listed once, as terms.
+func findAspects(terms []string, analysis *textbox.Analysis) []Aspect {
+	var aspects []Aspect
+	seen := make(map[string]bool)
+	for _, term := range terms {
+		aspects = append(aspects, Aspect{Name: term, Kind: aspectTerm})
+		seen[strings.ToLower(term)] = true
+	}
+	for _, sentence := range analysis.Sentences {
+		for _, e := range sentence.Entities {
+			key := strings.ToLower(e.Text)
+			if key == "" || seen[key] {
+				continue
+			}
+			aspects = append(aspects, Aspect{Name: e.Text, Kind: aspectEntity})
+			seen[key] = true
+		}
+	}
+	return aspects
+}
+
+// mentionedIn reports whether the clause mentions the aspect, as whole
+// words.
+func (a Aspect) mentionedIn(clause string) bool {
+	return matchesTerm(wordSet(clause), a.Name)
+}
+
+// scoreAspects works out the sentiment towards each aspect of a tweet. By
+// default every aspect gets the sentiment of the whole tweet. With Aspects
+// turned on, when the aspects are spread over several clauses, each of
+// those clauses is analyzed on its own and the aspects get the sentiment
+// of the clauses mentioning them. The clauses are split from the tweet's
+// text as ClauseNormalizer cleans it up, and each is normalized fully
+// before it is analyzed. If a clause can't be analyzed, its aspects keep
+// the tweet's sentiment.
+func (p *Pipeline) scoreAspects(ctx context.Context, analyzer Analyzer, text string, terms []string, analysis *textbox.Analysis, sentiment float64) []Aspect {
+	aspects := findAspects(terms, analysis)
+	for i := range aspects {
+		aspects[i].Sentiment = sentiment
+	}
+	if !p.Aspects || len(aspects) < 2 {
+		return aspects
+	}
+
+	// Find the clauses that mention the aspects.
+	type clause struct {
+		text    string
+		aspects []int
+	}
+	var clauses []clause
+	for _, c := range SplitClauses(p.ClauseNormalizer.Normalize(text)) {
+		lower := strings.ToLower(c)
+		var mentioned []int
+		for i, a := range aspects {
+			if a.mentionedIn(lower) {
+				mentioned = append(mentioned, i)
+			}
+		}
+		if len(mentioned) > 0 {
+			clauses = append(clauses, clause{c, mentioned})
+		}
+	}
+	if len(clauses) < 2 {
+		return aspects
+	}
+
+	// Analyze the clauses and average their sentiment for each aspect.
+	totals := make([]float64, len(aspects))
+	counts := make([]int, len(aspects))
+	for _, c := range clauses {
+		clauseText := p.Normalizer.Normalize(c.text)
+		if clauseText == "" {
+			continue
+		}
+		clauseAnalysis, err := analyzer.Analyze(ctx, clauseText)
+		if err != nil {
+			continue
+		}
+		s := Sentiment(clauseAnalysis)
+		for _, i := range c.aspects {
+			totals[i] += s
+			counts[i]++
+		}
+	}
+	for i := range aspects {
+		if counts[i] > 0 {
+			aspects[i].Sentiment = totals[i] / float64(counts[i])
+		}
+	}
+	return aspects
+}
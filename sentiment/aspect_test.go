@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+func TestSplitClauses(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"love Trump, hate Russia", []string{"love Trump", "hate Russia"}},
+		{"Trump is great but Russia is not!", []string{"Trump is great", "Russia is not"}},
+		{"Trump wins. Russia loses; nobody cares", []string{"Trump wins", "Russia loses", "nobody cares"}},
+		{"BUT is only a conjunction between words", []string{"BUT is only a conjunction between words"}},
+		{"no clauses here", []string{"no clauses here"}},
+		{"...!", nil},
+	}
+	for _, tt := range tests {
+		if got := SplitClauses(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("SplitClauses(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestFindAspects(t *testing.T) {
+	analysis := analysisWithEntities("Putin", "trump", "", "Putin")
+	got := findAspects([]string{"Trump", "Russia"}, analysis)
+	want := []Aspect{
+		{Name: "Trump", Kind: aspectTerm},
+		{Name: "Russia", Kind: aspectTerm},
+		{Name: "Putin", Kind: aspectEntity},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// clauseAnalyzer scores the texts it knows, and fails on any other.
+type clauseAnalyzer map[string]float64
+
+func (c clauseAnalyzer) Analyze(ctx context.Context, text string) (*textbox.Analysis, error) {
+	s, ok := c[text]
+	if !ok {
+		return nil, fmt.Errorf("unexpected text %q", text)
+	}
+	return &textbox.Analysis{Sentences: []textbox.Sentence{{Sentiment: s}}}, nil
+}
+
+func TestScoreAspects(t *testing.T) {
+	analyzer := clauseAnalyzer{"I love": 0.9, "I hate": 0.1, "Trump is great": 0.8, "Putin is awful": 0.2}
+	tests := []struct {
+		name     string
+		aspects  bool
+		text     string
+		terms    []string
+		entities []string
+		want     map[string]float64
+	}{
+		{
+			name:  "aspects off",
+			text:  "I love @Trump, but I hate @Russia",
+			terms: []string{"Trump", "Russia"},
+			want:  map[string]float64{"Trump": 0.5, "Russia": 0.5},
+		},
+		{
+			name:    "one aspect",
+			aspects: true,
+			text:    "I love @Trump, but I hate @Russia",
+			terms:   []string{"Trump"},
+			want:    map[string]float64{"Trump": 0.5},
+		},
+		{
+			name:    "mentioned terms",
+			aspects: true,
+			text:    "I love @Trump, but I hate @Russia",
+			terms:   []string{"Trump", "Russia"},
+			want:    map[string]float64{"Trump": 0.9, "Russia": 0.1},
+		},
+		{
+			name:     "term and entity",
+			aspects:  true,
+			text:     "Trump is great but Putin is awful https://t.co/x",
+			terms:    []string{"Trump"},
+			entities: []string{"Putin"},
+			want:     map[string]float64{"Trump": 0.8, "Putin": 0.2},
+		},
+		{
+			name:    "aspects in one clause",
+			aspects: true,
+			text:    "I love Trump and Russia",
+			terms:   []string{"Trump", "Russia"},
+			want:    map[string]float64{"Trump": 0.5, "Russia": 0.5},
+		},
+		{
+			name:    "clause can't be analyzed",
+			aspects: true,
+			text:    "I love @Trump, @Russia is fine",
+			terms:   []string{"Trump", "Russia"},
+			want:    map[string]float64{"Trump": 0.9, "Russia": 0.5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			normalizer, err := NewNormalizer(defaultNormalize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			clauseNormalizer, err := NewNormalizer(keepMentions(defaultNormalize))
+			if err != nil {
+				t.Fatal(err)
+			}
+			p := &Pipeline{Normalizer: normalizer, Aspects: tt.aspects, ClauseNormalizer: clauseNormalizer}
+
+			aspects := p.scoreAspects(context.Background(), analyzer, tt.text, tt.terms, analysisWithEntities(tt.entities...), 0.5)
+			got := make(map[string]float64)
+			for _, a := range aspects {
+				got[a.Name] = a.Sentiment
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// analysisWithEntities returns an analysis of one sentence with the named
+// entities.
+func analysisWithEntities(names ...string) *textbox.Analysis {
+	var sentence textbox.Sentence
+	for _, name := range names {
+		sentence.Entities = append(sentence.Entities, textbox.Entity{Text: name})
+	}
+	return &textbox.Analysis{Sentences: []textbox.Sentence{sentence}}
+}
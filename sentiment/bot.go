@@ -0,0 +1,176 @@
+package main
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// twitterTimeLayout is the layout of the timestamps in Twitter's API.
+const twitterTimeLayout = "Mon Jan 02 15:04:05 -0700 2006"
+
+// What to do with tweets from suspected bots.
+const (
+	botActionOff        = "off"
+	botActionFlag       = "flag"
+	botActionDownweight = "downweight"
+	botActionDrop       = "drop"
+)
+
+// Account kinds, for the bot/human breakdown of the stats.
+const (
+	accountBot   = "bot"
+	accountHuman = "human"
+)
+
+// BotDetector scores how likely tweets are to come from bots or spammers,
+// from 0 (human) to 1 (bot). It looks at the account (its age, its
+// followers compared to who it follows, how much it tweets and whether
+// the profile was ever changed), at the content (tweets that are nothing
+// but links, and the same text posted over and over) and at how often
+// each user posts to the stream. It is safe for concurrent use.
+type BotDetector struct {
+
+	// Window is how far back posting rates and repeated texts are counted
+	// (roughly; counts cover between one and two windows).
+	Window time.Duration
+
+	// MaxPosts is how many tweets a user can post within the window
+	// before they look like a bot.
+	MaxPosts int
+
+	// MaxRepeats is how many times the same text can be posted within the
+	// window before it looks like spam.
+	MaxRepeats int
+
+	mux       sync.Mutex
+	rotated   time.Time
+	posts     map[string]int
+	prevPosts map[string]int
+	texts     map[uint64]int
+	prevTexts map[uint64]int
+}
+
+// NewBotDetector creates a BotDetector with the default settings.
+func NewBotDetector() *BotDetector {
+	return &BotDetector{
+		Window:     10 * time.Minute,
+		MaxPosts:   10,
+		MaxRepeats: 3,
+		posts:      make(map[string]int),
+		prevPosts:  make(map[string]int),
+		texts:      make(map[uint64]int),
+		prevTexts:  make(map[uint64]int),
+	}
+}
+
+// Score scores a tweet received at now, and counts it towards the posting
+// rate of its user and the repeats of its text.
+func (d *BotDetector) Score(t Tweet, now time.Time) float64 {
+	score := accountScore(t.User, now) + contentScore(t.Text)
+
+	posts, repeats := d.count(t, now)
+	if posts > d.MaxPosts {
+		score += 0.25
+	}
+	if repeats > d.MaxRepeats {
+		score += 0.2
+	}
+	return math.Min(score, 1)
+}
+
+// count records the tweet and returns how many tweets its user has posted
+// and how many times its text has been posted within the window.
+func (d *BotDetector) count(t Tweet, now time.Time) (posts, repeats int) {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+
+	// Start a new window, keeping the last one, once the window is up.
+	if now.Sub(d.rotated) >= d.Window {
+		d.prevPosts, d.posts = d.posts, make(map[string]int)
+		d.prevTexts, d.texts = d.texts, make(map[uint64]int)
+		if now.Sub(d.rotated) >= 2*d.Window {
+			d.prevPosts = make(map[string]int)
+			d.prevTexts = make(map[uint64]int)
+		}
+		d.rotated = now
+	}
+
+	if t.User.ID != "" {
+		d.posts[t.User.ID]++
+		posts = d.posts[t.User.ID] + d.prevPosts[t.User.ID]
+	}
+
+	// Retweets repeat the text they retweet, so they don't count.
+	if text := spamText(t.Text); text != "" && !retweetRe.MatchString(t.Text) {
+		h := fnv.New64a()
+		h.Write([]byte(text))
+		key := h.Sum64()
+		d.texts[key]++
+		repeats = d.texts[key] + d.prevTexts[key]
+	}
+	return posts, repeats
+}
+
+// accountScore scores the account features of a user.
+func accountScore(u User, now time.Time) float64 {
+	score := 0.0
+
+	// New accounts.
+	days := 0.0
+	if created, err := time.Parse(twitterTimeLayout, u.CreatedAt); err == nil {
+		days = math.Max(now.Sub(created).Hours()/24, 1)
+		switch {
+		case days < 2:
+			score += 0.3
+		case days < 30:
+			score += 0.15
+		}
+	}
+
+	// Accounts following many more users than follow them back.
+	if u.FriendsCount > 100 && float64(u.FollowersCount) < 0.1*float64(u.FriendsCount) {
+		score += 0.15
+	}
+
+	// Accounts tweeting around the clock.
+	if days > 0 {
+		switch perDay := float64(u.StatusesCount) / days; {
+		case perDay > 144:
+			score += 0.25
+		case perDay > 50:
+			score += 0.1
+		}
+	}
+
+	// Accounts that never set up their profile.
+	if u.DefaultProfile {
+		score += 0.05
+	}
+	if u.DefaultProfileImage {
+		score += 0.1
+	}
+	return score
+}
+
+// contentScore scores the content features of a tweet's text.
+func contentScore(text string) float64 {
+
+	// Tweets that are nothing but links, mentions and hashtags.
+	if urlRe.MatchString(text) && spamText(text) == "" {
+		return 0.2
+	}
+	return 0
+}
+
+// spamText returns the words of text that are left without links,
+// mentions, hashtags and case, to compare texts that differ only in those.
+func spamText(text string) string {
+	text = retweetRe.ReplaceAllString(text, "")
+	text = urlRe.ReplaceAllString(text, "")
+	text = mentionRe.ReplaceAllString(text, "")
+	text = hashtagRe.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(strings.ToLower(text)), " ")
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestAccountScore(t *testing.T) {
+	now := time.Date(2018, 3, 8, 12, 0, 0, 0, time.UTC)
+	created := func(age time.Duration) string {
+		return now.Add(-age).Format(twitterTimeLayout)
+	}
+	year := 365 * 24 * time.Hour
+	tests := []struct {
+		name string
+		user User
+		want float64
+	}{
+		{"established account", User{CreatedAt: created(year), FollowersCount: 500, FriendsCount: 300, StatusesCount: 3650}, 0},
+		{"no creation time", User{}, 0},
+		{"a day old", User{CreatedAt: created(24 * time.Hour)}, 0.3},
+		{"ten days old", User{CreatedAt: created(10 * 24 * time.Hour)}, 0.15},
+		{"follows many more than follow it", User{CreatedAt: created(year), FollowersCount: 50, FriendsCount: 1000}, 0.15},
+		{"follows few", User{CreatedAt: created(year), FollowersCount: 0, FriendsCount: 100}, 0},
+		{"tweets around the clock", User{CreatedAt: created(year), StatusesCount: 200 * 365}, 0.25},
+		{"tweets a lot", User{CreatedAt: created(year), StatusesCount: 60 * 365}, 0.1},
+		{"default profile", User{CreatedAt: created(year), DefaultProfile: true, DefaultProfileImage: true}, 0.15},
+		{"everything", User{CreatedAt: created(time.Hour), FriendsCount: 2000, StatusesCount: 500, DefaultProfile: true, DefaultProfileImage: true}, 0.85},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := accountScore(tt.user, now); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestContentScore(t *testing.T) {
+	tests := []struct {
+		text string
+		want float64
+	}{
+		{"just some words", 0},
+		{"read this https://t.co/abc", 0},
+		{"https://t.co/abc", 0.2},
+		{"@someone #deal https://t.co/abc", 0.2},
+		{"@someone #deal", 0},
+	}
+	for _, tt := range tests {
+		if got := contentScore(tt.text); got != tt.want {
+			t.Errorf("contentScore(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBotDetector(t *testing.T) {
+	start := time.Date(2018, 3, 8, 12, 0, 0, 0, time.UTC)
+
+	// Each step is a tweet from a user with the text, received at an
+	// offset from start, and the score it should get. The users have no
+	// account details, so only the posting rate and repeats count.
+	type step struct {
+		user string
+		text string
+		at   time.Duration
+		want float64
+	}
+	posts := func(user string, n int, at time.Duration) []step {
+		var steps []step
+		for i := 0; i < n; i++ {
+			steps = append(steps, step{user, "tweet " + string(rune('a'+i)), at, 0})
+		}
+		return steps
+	}
+	repeats := func(n int, at time.Duration) []step {
+		var steps []step
+		for i := 0; i < n; i++ {
+			steps = append(steps, step{string(rune('a' + i)), "Buy now!", at, 0})
+		}
+		return steps
+	}
+	tests := []struct {
+		name  string
+		steps []step
+	}{
+		{
+			name:  "posting too often",
+			steps: append(posts("u", 10, 0), step{"u", "one more", 0, 0.25}),
+		},
+		{
+			name:  "other users aren't counted",
+			steps: append(posts("u", 10, 0), step{"v", "one more", 0, 0}),
+		},
+		{
+			name:  "previous window still counts",
+			steps: append(posts("u", 10, 0), step{"u", "one more", 15 * time.Minute, 0.25}),
+		},
+		{
+			name:  "posts age out after two windows",
+			steps: append(posts("u", 10, 0), step{"u", "one more", 25 * time.Minute, 0}),
+		},
+		{
+			name:  "repeated text",
+			steps: append(repeats(3, 0), step{"z", "buy NOW! https://t.co/x #deal", 0, 0.2}),
+		},
+		{
+			name:  "retweets aren't repeats",
+			steps: append(repeats(3, 0), step{"z", "RT @a: Buy now!", 0, 0}),
+		},
+		{
+			name:  "link-only spam posted too often",
+			steps: append(posts("u", 10, 0), step{"u", "https://t.co/x", 0, 0.45}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := NewBotDetector()
+			for i, s := range tt.steps {
+				got := d.Score(Tweet{Text: s.text, User: User{ID: s.user}}, start.Add(s.at))
+				if math.Abs(got-s.want) > 1e-9 {
+					t.Errorf("step %d: got %v, want %v", i, got, s.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// Messages are published to NATS in batches of up to natsBatchSize, and
+// a batch is retried if any of its messages isn't acknowledged within
+// natsAckTimeout. Close waits up to natsCloseTimeout for the buffer to
+// drain.
+const (
+	natsBatchSize    = 256
+	natsAckTimeout   = 5 * time.Second
+	natsRetryMax     = 30 * time.Second
+	natsCloseTimeout = 10 * time.Second
+)
+
+// NATSSink publishes scored tweets and stats snapshots to NATS JetStream,
+// so other services can consume them. A tweet is published on
+// <subject>.tweets.<term> for each tracked term it mentions (or
+// <subject>.tweets._ if it mentions none), with the tweet and term as its
+// message ID so JetStream drops duplicates. The stats go to
+// <subject>.stats, and the stats for each term to <subject>.stats.<term>.
+//
+// Messages are buffered while NATS is down or not acknowledging them, and
+// retried until they are acknowledged. Once the buffer is full the oldest
+// messages are dropped.
+type NATSSink struct {
+	nc      *nats.Conn
+	js      jetstream.JetStream
+	subject string
+	stream  string
+	buffer  *natsBuffer
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// OpenNATSSink connects to the NATS server at url and starts publishing.
+// If the server is down the connection keeps being retried, and messages
+// are buffered until it is up.
+func OpenNATSSink(url, subject, stream string, bufferSize int) (*NATSSink, error) {
+	nc, err := nats.Connect(url,
+		nats.Name("sentiment"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc, jetstream.WithPublishAsyncTimeout(natsAckTimeout))
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s := &NATSSink{
+		nc:      nc,
+		js:      js,
+		subject: subject,
+		stream:  stream,
+		buffer:  newNATSBuffer(bufferSize),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write buffers a scored tweet to be published.
+func (s *NATSSink) Write(t ScoredTweet) error {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+	terms := t.Terms
+	if len(terms) == 0 {
+		terms = []string{""}
+	}
+	for _, term := range terms {
+		msg := nats.NewMsg(s.subject + ".tweets." + subjectToken(term))
+		msg.Data = data
+		if t.ID != "" {
+			msg.Header.Set(jetstream.MsgIDHeader, t.ID+":"+term)
+		}
+		s.buffer.push(msg)
+	}
+	return nil
+}
+
+// WriteStats buffers a snapshot of the stats to be published.
+func (s *NATSSink) WriteStats(snapshot *Stats) error {
+	data, err := json.Marshal(snapshot.file())
+	if err != nil {
+		return err
+	}
+	msg := nats.NewMsg(s.subject + ".stats")
+	msg.Data = data
+	s.buffer.push(msg)
+
+	for term, b := range snapshot.Terms {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return err
+		}
+		msg := nats.NewMsg(s.subject + ".stats." + subjectToken(term))
+		msg.Data = data
+		s.buffer.push(msg)
+	}
+	return nil
+}
+
+// Close publishes the buffered messages, giving up after
+// natsCloseTimeout, and closes the connection.
+func (s *NATSSink) Close() error {
+	s.buffer.close()
+	select {
+	case <-s.done:
+	case <-time.After(natsCloseTimeout):
+		s.cancel()
+		<-s.done
+	}
+	s.cancel()
+	s.nc.Close()
+	if n := s.buffer.len(); n > 0 {
+		return fmt.Errorf("%d messages were not published to NATS", n)
+	}
+	return nil
+}
+
+// run publishes the buffered messages until the sink is closed.
+func (s *NATSSink) run() {
+	defer close(s.done)
+
+	var created bool
+	var delay time.Duration
+	for {
+		batch, ok := s.buffer.take(s.ctx, natsBatchSize)
+		if !ok {
+			return
+		}
+
+		// Make sure the stream exists, then publish the batch. If that
+		// fails, put it back and wait a little longer each time.
+		var err error
+		switch {
+		case !s.nc.IsConnected():
+			err = fmt.Errorf("not connected to NATS (%s)", s.nc.Status())
+		case !created:
+			if err = s.createStream(); err == nil {
+				created = true
+			}
+		}
+		var failed []*nats.Msg
+		if err == nil {
+			failed, err = s.publish(batch)
+		} else {
+			failed = batch
+		}
+		if len(failed) == 0 {
+			delay = 0
+			continue
+		}
+		s.buffer.requeue(failed)
+		if delay = 2 * delay; delay == 0 {
+			delay = 500 * time.Millisecond
+		} else if delay > natsRetryMax {
+			delay = natsRetryMax
+		}
+		fmt.Printf("Error publishing to NATS: %v (retrying %d messages in %v)\n", err, len(failed), delay)
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+}
+
+// createStream creates the JetStream stream for the subjects, or updates
+// it if it exists.
+func (s *NATSSink) createStream() error {
+	ctx, cancel := context.WithTimeout(s.ctx, natsAckTimeout)
+	defer cancel()
+	_, err := s.js.CreateOrUpdateStream(ctx, jetstream.StreamConfig{
+		Name:     s.stream,
+		Subjects: []string{s.subject + ".>"},
+	})
+	return err
+}
+
+// publish publishes a batch of messages and waits for them to be
+// acknowledged, returning those that weren't and the first error.
+func (s *NATSSink) publish(batch []*nats.Msg) ([]*nats.Msg, error) {
+	var failed []*nats.Msg
+	var firstErr error
+	fail := func(msg *nats.Msg, err error) {
+		failed = append(failed, msg)
+		natsPublishFailures.Inc()
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	futures := make([]jetstream.PubAckFuture, 0, len(batch))
+	for i, msg := range batch {
+		f, err := s.js.PublishMsgAsync(msg)
+		if err != nil {
+			for _, msg := range batch[i:] {
+				fail(msg, err)
+			}
+			break
+		}
+		futures = append(futures, f)
+	}
+	for _, f := range futures {
+		select {
+		case <-f.Ok():
+			natsPublished.Inc()
+		case err := <-f.Err():
+			fail(f.Msg(), err)
+		case <-s.ctx.Done():
+			fail(f.Msg(), s.ctx.Err())
+		}
+	}
+	return failed, firstErr
+}
+
+// subjectToken turns a term into a single NATS subject token, replacing
+// the characters subjects can't contain.
+func subjectToken(term string) string {
+	if term == "" {
+		return "_"
+	}
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '.', '*', '>', ' ', '\t', '\r', '\n':
+			return '_'
+		}
+		return r
+	}, strings.ToLower(term))
+}
+
+// validSubject reports whether s is a NATS subject without wildcards.
+func validSubject(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, token := range strings.Split(s, ".") {
+		if token == "" || strings.ContainsAny(token, "*> \t\r\n") {
+			return false
+		}
+	}
+	return true
+}
+
+// natsBuffer is a bounded queue of messages waiting to be published. When
+// it is full the oldest messages are dropped.
+type natsBuffer struct {
+	mu     sync.Mutex
+	ready  chan struct{}
+	msgs   []*nats.Msg
+	max    int
+	closed bool
+}
+
+func newNATSBuffer(max int) *natsBuffer {
+	return &natsBuffer{
+		ready: make(chan struct{}, 1),
+		max:   max,
+	}
+}
+
+// push adds a message to the end of the queue.
+func (b *natsBuffer) push(msg *nats.Msg) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.msgs = append(b.msgs, msg)
+	b.trim()
+	b.signal()
+}
+
+// requeue puts messages that failed back at the front of the queue.
+func (b *natsBuffer) requeue(msgs []*nats.Msg) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.msgs = append(msgs[:len(msgs):len(msgs)], b.msgs...)
+	b.trim()
+	b.signal()
+}
+
+// take removes up to n messages from the front of the queue, waiting for
+// some if it is empty. It returns false once the buffer is closed and
+// empty, or the context is done.
+func (b *natsBuffer) take(ctx context.Context, n int) ([]*nats.Msg, bool) {
+	for {
+		b.mu.Lock()
+		if len(b.msgs) > 0 {
+			if n > len(b.msgs) {
+				n = len(b.msgs)
+			}
+			batch := append([]*nats.Msg(nil), b.msgs[:n]...)
+			b.msgs = b.msgs[n:]
+			natsBuffered.Set(float64(len(b.msgs)))
+			b.mu.Unlock()
+			return batch, true
+		}
+		closed := b.closed
+		b.mu.Unlock()
+		if closed {
+			return nil, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, false
+		case <-b.ready:
+		}
+	}
+}
+
+// close stops take from waiting for more messages.
+func (b *natsBuffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.signal()
+}
+
+// len returns the number of messages in the queue.
+func (b *natsBuffer) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return len(b.msgs)
+}
+
+// trim drops the oldest messages beyond the limit. The lock must be held.
+func (b *natsBuffer) trim() {
+	if over := len(b.msgs) - b.max; over > 0 {
+		b.msgs = b.msgs[over:]
+		natsDropped.Add(float64(over))
+	}
+	natsBuffered.Set(float64(len(b.msgs)))
+}
+
+// signal wakes take. The lock must be held.
+func (b *natsBuffer) signal() {
+	select {
+	case b.ready <- struct{}{}:
+	default:
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	"github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestNATSBuffer(t *testing.T) {
+
+	// Each step pushes, requeues or takes the messages, named by their
+	// subjects.
+	type step struct {
+		op   string
+		msgs []string
+	}
+	tests := []struct {
+		name  string
+		max   int
+		steps []step
+		want  []string
+	}{
+		{
+			name:  "in order",
+			max:   10,
+			steps: []step{{"push", []string{"a", "b", "c"}}},
+			want:  []string{"a", "b", "c"},
+		},
+		{
+			name:  "full buffer drops the oldest",
+			max:   3,
+			steps: []step{{"push", []string{"a", "b", "c", "d", "e"}}},
+			want:  []string{"c", "d", "e"},
+		},
+		{
+			name: "requeued messages go first",
+			max:  10,
+			steps: []step{
+				{"push", []string{"a", "b", "c"}},
+				{"take", []string{"a", "b"}},
+				{"push", []string{"d"}},
+				{"requeue", []string{"a", "b"}},
+			},
+			want: []string{"a", "b", "c", "d"},
+		},
+		{
+			name: "requeue into a full buffer drops the oldest",
+			max:  3,
+			steps: []step{
+				{"push", []string{"a", "b", "c"}},
+				{"take", []string{"a", "b"}},
+				{"push", []string{"d", "e"}},
+				{"requeue", []string{"a", "b"}},
+			},
+			want: []string{"c", "d", "e"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newNATSBuffer(tt.max)
+			for _, s := range tt.steps {
+				switch s.op {
+				case "push":
+					for _, subject := range s.msgs {
+						b.push(nats.NewMsg(subject))
+					}
+				case "requeue":
+					var msgs []*nats.Msg
+					for _, subject := range s.msgs {
+						msgs = append(msgs, nats.NewMsg(subject))
+					}
+					b.requeue(msgs)
+				case "take":
+					if got := takeSubjects(t, b, len(s.msgs)); !reflect.DeepEqual(got, s.msgs) {
+						t.Fatalf("took %q, want %q", got, s.msgs)
+					}
+				}
+			}
+			if got := takeSubjects(t, b, tt.max); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNATSBufferClose(t *testing.T) {
+	b := newNATSBuffer(10)
+	b.push(nats.NewMsg("a"))
+	b.close()
+
+	// What is left can still be taken once the buffer is closed.
+	if got := takeSubjects(t, b, 10); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("got %q, want [a]", got)
+	}
+	if _, ok := b.take(context.Background(), 10); ok {
+		t.Error("take succeeded on a closed, empty buffer")
+	}
+}
+
+func TestNATSBufferTakeWaits(t *testing.T) {
+	b := newNATSBuffer(10)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		b.push(nats.NewMsg("a"))
+	}()
+	if got := takeSubjects(t, b, 10); !reflect.DeepEqual(got, []string{"a"}) {
+		t.Errorf("got %q, want [a]", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, ok := b.take(ctx, 10); ok {
+		t.Error("take succeeded on an empty buffer after the context was done")
+	}
+}
+
+func TestNATSSink(t *testing.T) {
+	srv := runJetStream(t, t.TempDir(), -1)
+	s, err := OpenNATSSink(srv.ClientURL(), "sentiment", "SENTIMENT", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := testutil.ToFloat64(natsPublished)
+
+	// The first tweet is sent twice, e.g., after a reconnection.
+	var trump, none ScoredTweet
+	trump.ID, trump.Terms = "1", []string{"Trump", "White House"}
+	none.ID = "2"
+	s.Write(trump)
+	s.Write(trump)
+	s.Write(none)
+	stats := NewStats()
+	stats.Track([]string{"Trump"})
+	if err := s.WriteStats(stats); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every message is acknowledged, but JetStream keeps only one copy of
+	// each tweet on each of its terms' subjects.
+	if got := testutil.ToFloat64(natsPublished) - published; got != 7 {
+		t.Errorf("got %v more messages published, want 7", got)
+	}
+	want := map[string]uint64{
+		"sentiment.tweets.trump":       1,
+		"sentiment.tweets.white_house": 1,
+		"sentiment.tweets._":           1,
+		"sentiment.stats":              1,
+		"sentiment.stats.trump":        1,
+	}
+	if got := streamSubjects(t, srv.ClientURL(), "SENTIMENT"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages on %v, want %v", got, want)
+	}
+}
+
+func TestNATSSinkBuffersWhileDown(t *testing.T) {
+	dir := t.TempDir()
+	srv := runJetStream(t, dir, -1)
+	port := srv.Addr().(*net.TCPAddr).Port
+	s, err := OpenNATSSink(srv.ClientURL(), "sentiment", "SENTIMENT", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published := testutil.ToFloat64(natsPublished)
+
+	tweet := func(id string) ScoredTweet {
+		var st ScoredTweet
+		st.ID, st.Terms = id, []string{"Trump"}
+		return st
+	}
+	s.Write(tweet("1"))
+	waitFor(t, "the first tweet to be published", func() bool {
+		return testutil.ToFloat64(natsPublished)-published == 1
+	})
+
+	// While the server is down the tweets are held, then published once
+	// it is back.
+	srv.Shutdown()
+	s.Write(tweet("2"))
+	s.Write(tweet("3"))
+	waitFor(t, "the tweets to be buffered", func() bool {
+		return testutil.ToFloat64(natsBuffered) == 2
+	})
+	srv = runJetStream(t, dir, port)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]uint64{"sentiment.tweets.trump": 3}
+	if got := streamSubjects(t, srv.ClientURL(), "SENTIMENT"); !reflect.DeepEqual(got, want) {
+		t.Errorf("got messages on %v, want %v", got, want)
+	}
+}
+
+func TestSubjectToken(t *testing.T) {
+	tests := []struct {
+		term string
+		want string
+	}{
+		{"", "_"},
+		{"Trump", "trump"},
+		{"climate change", "climate_change"},
+		{"a.b*c>d", "a_b_c_d"},
+	}
+	for _, tt := range tests {
+		if got := subjectToken(tt.term); got != tt.want {
+			t.Errorf("subjectToken(%q) = %q, want %q", tt.term, got, tt.want)
+		}
+		if !validSubject("sentiment.tweets." + subjectToken(tt.term)) {
+			t.Errorf("subjectToken(%q) isn't a valid subject token", tt.term)
+		}
+	}
+}
+
+// takeSubjects takes up to n messages from the buffer and returns their
+// subjects.
+func takeSubjects(t *testing.T, b *natsBuffer, n int) []string {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	msgs, ok := b.take(ctx, n)
+	if !ok {
+		t.Fatal("took no messages")
+	}
+	var subjects []string
+	for _, msg := range msgs {
+		subjects = append(subjects, msg.Subject)
+	}
+	return subjects
+}
+
+// runJetStream starts an embedded NATS server with JetStream, keeping its
+// streams in dir, on the given port, or any free one if port is -1.
+func runJetStream(t *testing.T, dir string, port int) *server.Server {
+	opts := test.DefaultTestOptions
+	opts.Port = port
+	opts.JetStream = true
+	opts.StoreDir = dir
+	srv := test.RunServer(&opts)
+	t.Cleanup(srv.Shutdown)
+	return srv
+}
+
+// streamSubjects returns the number of messages in the stream on each
+// subject.
+func streamSubjects(t *testing.T, url, stream string) map[string]uint64 {
+	nc, err := nats.Connect(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	st, err := js.Stream(ctx, stream)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := st.Info(ctx, jetstream.WithSubjectFilter(">"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return info.State.Subjects
+}
+
+// waitFor waits up to 10 seconds for cond to be true.
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("gave up waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"time"
+)
+
+// We use two kinds of HTTP clients, both built on the same transport
+// settings:
+//
+//   - A streaming client for Twitter's statuses/filter endpoint. The
+//     response body is a single long-lived stream, so the client has no
+//     overall timeout (it would cut the stream off). Only connecting, the
+//     TLS handshake and waiting for the response headers are bounded, and
+//     the stream is stopped by cancelling the request's context.
+//   - A request/response client for MachineBox. Every call is short, so
+//     the client has an overall timeout and keeps a pool of idle
+//     connections that all of the workers share.
+
+// newTransport creates an http.Transport with timeouts, connection pooling
+// limits and proxy support from the environment (HTTP_PROXY, HTTPS_PROXY
+// and NO_PROXY).
+func newTransport(maxConnsPerHost int) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   maxConnsPerHost,
+		MaxConnsPerHost:       maxConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+	}
+}
+
+// NewStreamingClient creates an HTTP client for long-lived streaming
+// responses.
+func NewStreamingClient() *http.Client {
+	return &http.Client{
+		Transport: newTransport(2),
+	}
+}
+
+// NewClient creates an HTTP client for request/response APIs, allowing up
+// to maxConns concurrent connections per host.
+func NewClient(timeout time.Duration, maxConns int) *http.Client {
+	return &http.Client{
+		Transport: newTransport(maxConns),
+		Timeout:   timeout,
+	}
+}
@@ -0,0 +1,639 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+const (
+	defaultDeadLetterPath = "deadletter.jsonl"
+	defaultStatsPath      = "stats.json"
+)
+
+// Config is the configuration for the sentiment command. It is built up,
+// in order of increasing precedence, from the defaults, a YAML config file,
+// SENTIMENT_* environment variables and command line flags.
+type Config struct {
+	Twitter TwitterConfig `yaml:"twitter"`
+
+	// Terms are the terms to track on the stream, Follow the IDs of users
+	// to follow and Locations the bounding boxes to get tweets from.
+	Terms           []string      `yaml:"terms"`
+	Follow          []string      `yaml:"follow"`
+	Locations       []BoundingBox `yaml:"locations"`
+	StrictLocations bool          `yaml:"strict_locations"`
+
+	// Analyzer is the analyzer backend. The only backend so far is
+	// "machinebox".
+	Analyzer   string `yaml:"analyzer"`
+	MachineBox string `yaml:"machinebox"`
+
+	// Languages are the languages the analyzer handles; tweets in other
+	// languages are skipped unless LanguageAnalyzers has an address of an
+	// analyzer for them. An empty list sends every language to the
+	// analyzer.
+	Languages         []string          `yaml:"languages"`
+	LanguageAnalyzers map[string]string `yaml:"language_analyzers"`
+
+	// Normalize are the normalization steps applied to the text before it
+	// is analyzed, in order.
+	Normalize []string `yaml:"normalize"`
+
+	// EmojiWeight is how much the emoji and emoticons in a tweet count
+	// towards its sentiment, from 0 (not at all) to 1 (only they count).
+	EmojiWeight float64 `yaml:"emoji_weight"`
+
+	// Aspects analyzes the clauses of tweets that mention several terms
+	// or entities separately. It costs an extra analyzer call per clause.
+	Aspects bool `yaml:"aspects"`
+
+	// BotAction is what to do with tweets whose bot score is at least
+	// BotThreshold: "off" (don't score them), "flag" (only break the stats
+	// down by bots and humans), "downweight" (count them with BotWeight)
+	// or "drop".
+	BotAction    string  `yaml:"bot_action"`
+	BotThreshold float64 `yaml:"bot_threshold"`
+	BotWeight    float64 `yaml:"bot_weight"`
+
+	// Influence are the schemes ("followers", "engagement", "verified")
+	// used to weight tweets by the influence of their author. The weighted
+	// averages are reported next to the unweighted ones.
+	Influence []string `yaml:"influence"`
+
+	// TrendWindow is the sliding window trending hashtags, keywords and
+	// n-grams are counted over, and TrendBaseline the longer period their
+	// usual frequency is averaged over.
+	TrendWindow   time.Duration `yaml:"trend_window"`
+	TrendBaseline time.Duration `yaml:"trend_baseline"`
+
+	Workers        int           `yaml:"workers"`
+	Duration       time.Duration `yaml:"duration"`
+	ReportInterval time.Duration `yaml:"report_interval"`
+	Ordered        bool          `yaml:"ordered"`
+
+	// HTTPAddr is the address the HTTP API listens on. It is off if empty.
+	// AdminToken is the bearer token needed to change the tracked terms
+	// through the API; the admin endpoints are off without one.
+	HTTPAddr   string `yaml:"http_addr"`
+	AdminToken string `yaml:"admin_token"`
+
+	// StatsPath is where the stats are saved, every CheckpointInterval
+	// (if it isn't 0) and when the run ends, and loaded from on start.
+	StatsPath          string        `yaml:"stats_path"`
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+	DeadLetterPath     string        `yaml:"dead_letter_path"`
+
+	// SQLitePath is the SQLite database each scored tweet is written to,
+	// for the query command. It is off if empty.
+	SQLitePath string `yaml:"sqlite_path"`
+
+	// Outputs are the files scored tweets are written to, as format:path
+	// pairs like jsonl:results/tweets. A new file is started every
+	// RotateInterval or once it reaches RotateSize bytes (either may be 0
+	// to turn it off), and buffered tweets are written every
+	// FlushInterval, except to Parquet files, which are written a row
+	// group at a time.
+	Outputs        []string      `yaml:"outputs"`
+	RotateSize     int64         `yaml:"rotate_size"`
+	RotateInterval time.Duration `yaml:"rotate_interval"`
+	FlushInterval  time.Duration `yaml:"flush_interval"`
+
+	// NATSURL is the NATS server scored tweets and stats are published
+	// to, under the NATSSubject prefix, and stored in the NATSStream
+	// JetStream stream. It is off if empty. Up to NATSBuffer messages are
+	// held while NATS is down.
+	NATSURL     string `yaml:"nats_url"`
+	NATSSubject string `yaml:"nats_subject"`
+	NATSStream  string `yaml:"nats_stream"`
+	NATSBuffer  int    `yaml:"nats_buffer"`
+
+	// InputNATS is the NATS server to consume tweets from instead of
+	// Twitter, on InputSubject in the InputStream JetStream stream (looked
+	// up from the subject if empty). Analyzers with the same InputGroup
+	// share the tweets between them.
+	InputNATS    string `yaml:"input_nats"`
+	InputSubject string `yaml:"input_subject"`
+	InputStream  string `yaml:"input_stream"`
+	InputGroup   string `yaml:"input_group"`
+
+	// Query holds the query command's options, which only come from its
+	// flags.
+	Query QueryOptions `yaml:"-"`
+}
+
+// TwitterConfig holds the Twitter app credentials.
+type TwitterConfig struct {
+	ConsumerKey    string `yaml:"consumer_key"`
+	ConsumerSecret string `yaml:"consumer_secret"`
+	AccessToken    string `yaml:"access_token"`
+	AccessSecret   string `yaml:"access_secret"`
+}
+
+// DefaultConfig returns the default configuration.
+func DefaultConfig() Config {
+	return Config{
+		Analyzer:           "machinebox",
+		MachineBox:         "http://localhost:8080",
+		Languages:          []string{"en"},
+		Normalize:          defaultNormalize,
+		BotAction:          botActionFlag,
+		BotThreshold:       0.5,
+		BotWeight:          0.25,
+		TrendWindow:        5 * time.Minute,
+		TrendBaseline:      time.Hour,
+		Workers:            3,
+		ReportInterval:     time.Second,
+		StatsPath:          defaultStatsPath,
+		CheckpointInterval: time.Minute,
+		RotateSize:         100 * 1024 * 1024,
+		RotateInterval:     time.Hour,
+		FlushInterval:      time.Second,
+		NATSSubject:        "sentiment",
+		NATSStream:         "SENTIMENT",
+		NATSBuffer:         10000,
+		InputSubject:       "tweets",
+		InputGroup:         "sentiment",
+		DeadLetterPath:     defaultDeadLetterPath,
+	}
+}
+
+// listFlag is a comma-separated list flag.
+type listFlag struct {
+	list *[]string
+}
+
+func (l listFlag) String() string {
+	if l.list == nil {
+		return ""
+	}
+	return strings.Join(*l.list, ",")
+}
+
+func (l listFlag) Set(s string) error {
+	*l.list = splitList(s)
+	return nil
+}
+
+// mapFlag is a comma-separated list of key=value pairs.
+type mapFlag struct {
+	m *map[string]string
+}
+
+func (f mapFlag) String() string {
+	if f.m == nil {
+		return ""
+	}
+	var pairs []string
+	for k, v := range *f.m {
+		pairs = append(pairs, k+"="+v)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func (f mapFlag) Set(s string) error {
+	m, err := splitMap(s)
+	if err != nil {
+		return err
+	}
+	*f.m = m
+	return nil
+}
+
+// splitMap splits a comma-separated list of key=value pairs.
+func splitMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	for _, pair := range splitList(s) {
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%q is not a key=value pair", pair)
+		}
+		m[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+	}
+	return m, nil
+}
+
+// locationsFlag is a comma-separated list of bounding box coordinates.
+type locationsFlag struct {
+	boxes *[]BoundingBox
+}
+
+func (l locationsFlag) String() string {
+	if l.boxes == nil {
+		return ""
+	}
+	return Filter{Locations: *l.boxes}.Values().Get("locations")
+}
+
+func (l locationsFlag) Set(s string) error {
+	boxes, err := ParseLocations(s)
+	if err != nil {
+		return err
+	}
+	*l.boxes = boxes
+	return nil
+}
+
+// splitList splits a comma-separated list, dropping empty items.
+func splitList(s string) []string {
+	var list []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+// flagSet returns a flag set for the named command that writes into c.
+func (c *Config) flagSet(name string) (*flag.FlagSet, *string) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("SENTIMENT_CONFIG"), "YAML config file")
+	fs.StringVar(&c.Twitter.ConsumerKey, "consumer-key", c.Twitter.ConsumerKey, "Twitter consumer key")
+	fs.StringVar(&c.Twitter.ConsumerSecret, "consumer-secret", c.Twitter.ConsumerSecret, "Twitter consumer secret")
+	fs.StringVar(&c.Twitter.AccessToken, "access-token", c.Twitter.AccessToken, "Twitter access token")
+	fs.StringVar(&c.Twitter.AccessSecret, "access-secret", c.Twitter.AccessSecret, "Twitter access token secret")
+	fs.Var(listFlag{&c.Terms}, "terms", "comma-separated terms to track")
+	fs.Var(listFlag{&c.Follow}, "follow", "comma-separated IDs of users to follow")
+	fs.Var(locationsFlag{&c.Locations}, "locations", "comma-separated bounding boxes to track, as sw_lng,sw_lat,ne_lng,ne_lat,...")
+	fs.BoolVar(&c.StrictLocations, "strict-locations", c.StrictLocations, "drop tweets that only match on location but fall outside every box")
+	fs.StringVar(&c.Analyzer, "analyzer", c.Analyzer, "analyzer backend (machinebox)")
+	fs.StringVar(&c.MachineBox, "machinebox", c.MachineBox, "MachineBox textbox address")
+	fs.Var(listFlag{&c.Languages}, "languages", "comma-separated languages to analyze (empty for all)")
+	fs.Var(mapFlag{&c.LanguageAnalyzers}, "language-analyzers", "comma-separated lang=address pairs of language-specific analyzers")
+	fs.Var(listFlag{&c.Normalize}, "normalize", "comma-separated normalization steps ("+strings.Join(normalizeStepNames(), ", ")+")")
+	fs.Float64Var(&c.EmojiWeight, "emoji-weight", c.EmojiWeight, "weight of the emoji sentiment in the score, from 0 to 1")
+	fs.BoolVar(&c.Aspects, "aspects", c.Aspects, "analyze clauses separately for tweets mentioning several terms or entities")
+	fs.StringVar(&c.BotAction, "bot-action", c.BotAction, "what to do with suspected bots (off, flag, downweight, drop)")
+	fs.Float64Var(&c.BotThreshold, "bot-threshold", c.BotThreshold, "bot score, from 0 to 1, from which tweets are suspected bots")
+	fs.Float64Var(&c.BotWeight, "bot-weight", c.BotWeight, "weight of suspected bots' tweets when down-weighting, from 0 to 1")
+	fs.Var(listFlag{&c.Influence}, "influence", "comma-separated schemes weighting tweets by their author's influence ("+strings.Join(influenceSchemeNames(), ", ")+")")
+	fs.DurationVar(&c.TrendWindow, "trend-window", c.TrendWindow, "sliding window trending items are counted over")
+	fs.DurationVar(&c.TrendBaseline, "trend-baseline", c.TrendBaseline, "period the usual frequency of trending items is averaged over")
+	fs.IntVar(&c.Workers, "workers", c.Workers, "number of tweet workers")
+	fs.DurationVar(&c.Duration, "duration", c.Duration, "how long to run for (0 runs until interrupted)")
+	fs.DurationVar(&c.ReportInterval, "report-interval", c.ReportInterval, "how often to print the stats")
+	fs.BoolVar(&c.Ordered, "ordered", c.Ordered, "keep scored tweets in arrival order")
+	fs.StringVar(&c.HTTPAddr, "http", c.HTTPAddr, "address to serve the HTTP API on, like :8000 (off if empty)")
+	fs.StringVar(&c.AdminToken, "admin-token", c.AdminToken, "bearer token for the admin endpoints (off if empty)")
+	fs.StringVar(&c.StatsPath, "stats", c.StatsPath, "file the stats are saved to")
+	fs.DurationVar(&c.CheckpointInterval, "checkpoint-interval", c.CheckpointInterval, "how often to save the stats while running (0 only saves them at the end)")
+	fs.StringVar(&c.DeadLetterPath, "deadletter", c.DeadLetterPath, "file failed tweets are written to")
+	fs.StringVar(&c.SQLitePath, "sqlite", c.SQLitePath, "SQLite database scored tweets are written to (off if empty)")
+	fs.Var(listFlag{&c.Outputs}, "outputs", "comma-separated format:path files scored tweets are written to ("+strings.Join(fileFormatNames(), ", ")+")")
+	fs.Int64Var(&c.RotateSize, "rotate-size", c.RotateSize, "size in bytes at which to start a new output file (0 for no limit)")
+	fs.DurationVar(&c.RotateInterval, "rotate-interval", c.RotateInterval, "how often to start a new output file (0 for never)")
+	fs.DurationVar(&c.FlushInterval, "flush-interval", c.FlushInterval, "how often to write buffered tweets to the JSONL and CSV output files")
+	fs.StringVar(&c.NATSURL, "nats", c.NATSURL, "NATS server scored tweets and stats are published to, like nats://localhost:4222 (off if empty)")
+	fs.StringVar(&c.NATSSubject, "nats-subject", c.NATSSubject, "prefix of the NATS subjects published to")
+	fs.StringVar(&c.NATSStream, "nats-stream", c.NATSStream, "JetStream stream the published messages are stored in")
+	fs.IntVar(&c.NATSBuffer, "nats-buffer", c.NATSBuffer, "messages held while NATS is down before the oldest are dropped")
+	fs.StringVar(&c.InputNATS, "input-nats", c.InputNATS, "NATS server to consume tweets from instead of Twitter, like nats://localhost:4222")
+	fs.StringVar(&c.InputSubject, "input-subject", c.InputSubject, "NATS subject the tweets are consumed from")
+	fs.StringVar(&c.InputStream, "input-stream", c.InputStream, "JetStream stream the tweets are stored in (looked up from the subject if empty)")
+	fs.StringVar(&c.InputGroup, "input-group", c.InputGroup, "consumer group sharing the tweets between analyzers")
+	if name == "query" {
+		fs.StringVar(&c.Query.From, "from", c.Query.From, "start of the time range, as a time, a date or a duration ago like 168h (default 24h)")
+		fs.StringVar(&c.Query.To, "to", c.Query.To, "end of the time range, not included, as a time, a date (up to the end of that day) or a duration ago (default now)")
+		fs.StringVar(&c.Query.Term, "term", c.Query.Term, "only count tweets about this term")
+		fs.Var(listFlag{&c.Query.By}, "by", "comma-separated groupings (hour or day, and term)")
+	}
+	return fs, configPath
+}
+
+// LoadConfig builds the configuration for the named command from the
+// defaults, the config file, the environment and the command line args.
+func LoadConfig(name string, args []string) (Config, error) {
+
+	// Parse the flags once just to find the config file.
+	c := DefaultConfig()
+	fs, configPath := c.flagSet(name)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+
+	// Load the config file and the environment, then parse the flags
+	// again so they take precedence.
+	c = DefaultConfig()
+	if *configPath != "" {
+		data, err := os.ReadFile(*configPath)
+		if err != nil {
+			return c, fmt.Errorf("reading config file: %v", err)
+		}
+		if err := yaml.UnmarshalStrict(data, &c); err != nil {
+			return c, fmt.Errorf("parsing config file %s: %v", *configPath, err)
+		}
+	}
+	if err := c.loadEnv(); err != nil {
+		return c, err
+	}
+	fs, _ = c.flagSet(name)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+// commandArgs returns the args left after the flags of the named command.
+func commandArgs(name string, args []string) []string {
+	var c Config
+	fs, _ := c.flagSet(name)
+	fs.SetOutput(io.Discard)
+	if err := fs.Parse(args); err != nil {
+		return nil
+	}
+	return fs.Args()
+}
+
+// loadEnv overrides the config with any SENTIMENT_* environment variables.
+func (c *Config) loadEnv() error {
+	strs := map[string]*string{
+		"SENTIMENT_CONSUMER_KEY":    &c.Twitter.ConsumerKey,
+		"SENTIMENT_CONSUMER_SECRET": &c.Twitter.ConsumerSecret,
+		"SENTIMENT_ACCESS_TOKEN":    &c.Twitter.AccessToken,
+		"SENTIMENT_ACCESS_SECRET":   &c.Twitter.AccessSecret,
+		"SENTIMENT_ANALYZER":        &c.Analyzer,
+		"SENTIMENT_MACHINEBOX":      &c.MachineBox,
+		"SENTIMENT_STATS":           &c.StatsPath,
+		"SENTIMENT_DEADLETTER":      &c.DeadLetterPath,
+		"SENTIMENT_SQLITE":          &c.SQLitePath,
+		"SENTIMENT_NATS_URL":        &c.NATSURL,
+		"SENTIMENT_NATS_SUBJECT":    &c.NATSSubject,
+		"SENTIMENT_NATS_STREAM":     &c.NATSStream,
+		"SENTIMENT_INPUT_NATS":      &c.InputNATS,
+		"SENTIMENT_INPUT_SUBJECT":   &c.InputSubject,
+		"SENTIMENT_INPUT_STREAM":    &c.InputStream,
+		"SENTIMENT_INPUT_GROUP":     &c.InputGroup,
+	}
+	for name, p := range strs {
+		if v, ok := os.LookupEnv(name); ok {
+			*p = v
+		}
+	}
+
+	if v, ok := os.LookupEnv("SENTIMENT_TERMS"); ok {
+		c.Terms = splitList(v)
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_FOLLOW"); ok {
+		c.Follow = splitList(v)
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_LANGUAGES"); ok {
+		c.Languages = splitList(v)
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_NORMALIZE"); ok {
+		c.Normalize = splitList(v)
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_OUTPUTS"); ok {
+		c.Outputs = splitList(v)
+	}
+
+	var err error
+	if v, ok := os.LookupEnv("SENTIMENT_LANGUAGE_ANALYZERS"); ok {
+		if c.LanguageAnalyzers, err = splitMap(v); err != nil {
+			return fmt.Errorf("SENTIMENT_LANGUAGE_ANALYZERS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_LOCATIONS"); ok {
+		if c.Locations, err = ParseLocations(v); err != nil {
+			return fmt.Errorf("SENTIMENT_LOCATIONS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_STRICT_LOCATIONS"); ok {
+		if c.StrictLocations, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("SENTIMENT_STRICT_LOCATIONS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_EMOJI_WEIGHT"); ok {
+		if c.EmojiWeight, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("SENTIMENT_EMOJI_WEIGHT: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_ASPECTS"); ok {
+		if c.Aspects, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("SENTIMENT_ASPECTS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_INFLUENCE"); ok {
+		c.Influence = splitList(v)
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_BOT_ACTION"); ok {
+		c.BotAction = v
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_BOT_THRESHOLD"); ok {
+		if c.BotThreshold, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("SENTIMENT_BOT_THRESHOLD: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_BOT_WEIGHT"); ok {
+		if c.BotWeight, err = strconv.ParseFloat(v, 64); err != nil {
+			return fmt.Errorf("SENTIMENT_BOT_WEIGHT: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_TREND_WINDOW"); ok {
+		if c.TrendWindow, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_TREND_WINDOW: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_TREND_BASELINE"); ok {
+		if c.TrendBaseline, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_TREND_BASELINE: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_WORKERS"); ok {
+		if c.Workers, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("SENTIMENT_WORKERS: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_DURATION"); ok {
+		if c.Duration, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_DURATION: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_REPORT_INTERVAL"); ok {
+		if c.ReportInterval, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_REPORT_INTERVAL: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_CHECKPOINT_INTERVAL"); ok {
+		if c.CheckpointInterval, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_CHECKPOINT_INTERVAL: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_ROTATE_SIZE"); ok {
+		if c.RotateSize, err = strconv.ParseInt(v, 10, 64); err != nil {
+			return fmt.Errorf("SENTIMENT_ROTATE_SIZE: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_ROTATE_INTERVAL"); ok {
+		if c.RotateInterval, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_ROTATE_INTERVAL: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_FLUSH_INTERVAL"); ok {
+		if c.FlushInterval, err = time.ParseDuration(v); err != nil {
+			return fmt.Errorf("SENTIMENT_FLUSH_INTERVAL: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_NATS_BUFFER"); ok {
+		if c.NATSBuffer, err = strconv.Atoi(v); err != nil {
+			return fmt.Errorf("SENTIMENT_NATS_BUFFER: %v", err)
+		}
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_HTTP_ADDR"); ok {
+		c.HTTPAddr = v
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_ADMIN_TOKEN"); ok {
+		c.AdminToken = v
+	}
+	if v, ok := os.LookupEnv("SENTIMENT_ORDERED"); ok {
+		if c.Ordered, err = strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("SENTIMENT_ORDERED: %v", err)
+		}
+	}
+	return nil
+}
+
+// ValidateAnalyzer checks the settings needed to analyze tweets.
+func (c Config) ValidateAnalyzer() error {
+	return joinProblems(c.analyzerProblems())
+}
+
+// analyzerProblems lists what is wrong with the analyzer settings.
+func (c Config) analyzerProblems() []string {
+	var problems []string
+	switch c.Analyzer {
+	case "machinebox":
+		if !isURL(c.MachineBox) {
+			problems = append(problems, fmt.Sprintf("-machinebox %q is not a URL like http://localhost:8080", c.MachineBox))
+		}
+		for lang, addr := range c.LanguageAnalyzers {
+			if !isURL(addr) {
+				problems = append(problems, fmt.Sprintf("-language-analyzers address %q for %s is not a URL like http://localhost:8081", addr, lang))
+			}
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("-analyzer %q is not a known analyzer (machinebox)", c.Analyzer))
+	}
+	if c.EmojiWeight < 0 || c.EmojiWeight > 1 {
+		problems = append(problems, fmt.Sprintf("-emoji-weight %v must be between 0 and 1", c.EmojiWeight))
+	}
+	switch c.BotAction {
+	case botActionOff, botActionFlag, botActionDownweight, botActionDrop:
+	default:
+		problems = append(problems, fmt.Sprintf("-bot-action %q is not one of off, flag, downweight or drop", c.BotAction))
+	}
+	if c.BotThreshold < 0 || c.BotThreshold > 1 {
+		problems = append(problems, fmt.Sprintf("-bot-threshold %v must be between 0 and 1", c.BotThreshold))
+	}
+	if c.BotWeight <= 0 || c.BotWeight > 1 {
+		problems = append(problems, fmt.Sprintf("-bot-weight %v must be more than 0 and at most 1", c.BotWeight))
+	}
+	if err := validateInfluence(c.Influence); err != nil {
+		problems = append(problems, "-influence: "+err.Error())
+	}
+	if _, err := NewNormalizer(c.Normalize); err != nil {
+		problems = append(problems, "-normalize: "+err.Error())
+	}
+	return problems
+}
+
+// Filter returns the stream filter for the config.
+func (c Config) Filter() Filter {
+	return Filter{
+		Track:           c.Terms,
+		Follow:          c.Follow,
+		Locations:       c.Locations,
+		StrictLocations: c.StrictLocations,
+	}
+}
+
+// isURL reports whether s is an absolute URL.
+func isURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// ValidateStream checks the settings needed to stream tweets.
+func (c Config) ValidateStream() error {
+	problems := c.analyzerProblems()
+	if c.InputNATS == "" && (c.Twitter.ConsumerKey == "" || c.Twitter.ConsumerSecret == "" ||
+		c.Twitter.AccessToken == "" || c.Twitter.AccessSecret == "") {
+		problems = append(problems, "the Twitter consumer key and secret and access token and secret are all required (see the README for how to create a Twitter app)")
+	}
+	problems = append(problems, c.Filter().Validate()...)
+	if c.Workers < 1 {
+		problems = append(problems, fmt.Sprintf("-workers must be at least 1, not %d", c.Workers))
+	}
+	if c.Duration < 0 {
+		problems = append(problems, fmt.Sprintf("-duration must not be negative, not %v", c.Duration))
+	}
+	if c.ReportInterval <= 0 {
+		problems = append(problems, fmt.Sprintf("-report-interval must be positive, not %v", c.ReportInterval))
+	}
+	if c.CheckpointInterval < 0 {
+		problems = append(problems, fmt.Sprintf("-checkpoint-interval must not be negative, not %v", c.CheckpointInterval))
+	}
+	if err := validateOutputs(c.Outputs); err != nil {
+		problems = append(problems, "-outputs: "+err.Error())
+	}
+	if c.RotateSize < 0 {
+		problems = append(problems, fmt.Sprintf("-rotate-size must not be negative, not %d", c.RotateSize))
+	}
+	if c.RotateInterval < 0 {
+		problems = append(problems, fmt.Sprintf("-rotate-interval must not be negative, not %v", c.RotateInterval))
+	}
+	if c.FlushInterval <= 0 {
+		problems = append(problems, fmt.Sprintf("-flush-interval must be positive, not %v", c.FlushInterval))
+	}
+	if c.NATSURL != "" {
+		if !validSubject(c.NATSSubject) {
+			problems = append(problems, fmt.Sprintf("-nats-subject %q is not a valid NATS subject", c.NATSSubject))
+		}
+		if c.NATSStream == "" || strings.ContainsAny(c.NATSStream, " .*>/\\") {
+			problems = append(problems, fmt.Sprintf("-nats-stream %q is not a valid stream name", c.NATSStream))
+		}
+		if c.NATSBuffer < 1 {
+			problems = append(problems, fmt.Sprintf("-nats-buffer must be at least 1, not %d", c.NATSBuffer))
+		}
+	}
+	if c.InputNATS != "" {
+		if c.InputSubject == "" || strings.ContainsAny(c.InputSubject, " \t\r\n") {
+			problems = append(problems, fmt.Sprintf("-input-subject %q is not a valid NATS subject", c.InputSubject))
+		}
+		if strings.ContainsAny(c.InputStream, " .*>/\\") {
+			problems = append(problems, fmt.Sprintf("-input-stream %q is not a valid stream name", c.InputStream))
+		}
+		if c.InputGroup == "" || strings.ContainsAny(c.InputGroup, " .*>/\\") {
+			problems = append(problems, fmt.Sprintf("-input-group %q is not a valid consumer name", c.InputGroup))
+		}
+		if c.NATSURL != "" && c.InputStream == c.NATSStream {
+			problems = append(problems, fmt.Sprintf("-input-stream and -nats-stream must differ, not both %q", c.NATSStream))
+		}
+	}
+	if c.TrendWindow < trendBuckets*time.Second {
+		problems = append(problems, fmt.Sprintf("-trend-window must be at least %v, not %v", trendBuckets*time.Second, c.TrendWindow))
+	}
+	if c.TrendBaseline <= c.TrendWindow {
+		problems = append(problems, fmt.Sprintf("-trend-baseline %v must be longer than -trend-window %v", c.TrendBaseline, c.TrendWindow))
+	}
+	return joinProblems(problems)
+}
+
+// joinProblems combines validation problems into a single error.
+func joinProblems(problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid configuration:\n  " + strings.Join(problems, "\n  "))
+}
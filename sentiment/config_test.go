@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name        string
+		yaml        string
+		env         map[string]string
+		args        []string
+		wantWorkers int
+		wantTerms   []string
+	}{
+		{
+			name:        "defaults",
+			wantWorkers: 3,
+		},
+		{
+			name:        "config file",
+			yaml:        "workers: 5\nterms: [Trump, Russia]\n",
+			wantWorkers: 5,
+			wantTerms:   []string{"Trump", "Russia"},
+		},
+		{
+			name:        "env over config file",
+			yaml:        "workers: 5\nterms: [Trump, Russia]\n",
+			env:         map[string]string{"SENTIMENT_WORKERS": "7", "SENTIMENT_TERMS": "Putin, Russia"},
+			wantWorkers: 7,
+			wantTerms:   []string{"Putin", "Russia"},
+		},
+		{
+			name:        "flags over env",
+			yaml:        "workers: 5\n",
+			env:         map[string]string{"SENTIMENT_WORKERS": "7", "SENTIMENT_TERMS": "Putin"},
+			args:        []string{"-workers", "9", "-terms", "Trump,Putin"},
+			wantWorkers: 9,
+			wantTerms:   []string{"Trump", "Putin"},
+		},
+		{
+			name:        "flags over config file",
+			yaml:        "workers: 5\nterms: [Russia]\n",
+			args:        []string{"-workers", "9"},
+			wantWorkers: 9,
+			wantTerms:   []string{"Russia"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.yaml != "" {
+				args = append([]string{"-config", writeConfig(t, tt.yaml)}, args...)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			c, err := LoadConfig("stream", args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.Workers != tt.wantWorkers {
+				t.Errorf("got %d workers, want %d", c.Workers, tt.wantWorkers)
+			}
+			if !reflect.DeepEqual(c.Terms, tt.wantTerms) {
+				t.Errorf("got terms %q, want %q", c.Terms, tt.wantTerms)
+			}
+		})
+	}
+}
+
+func TestLoadConfigFromEnvFile(t *testing.T) {
+	t.Setenv("SENTIMENT_CONFIG", writeConfig(t, "machinebox: http://textbox:8080\n"))
+	c, err := LoadConfig("stream", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.MachineBox != "http://textbox:8080" {
+		t.Errorf("got machinebox %q, want the one from the config file", c.MachineBox)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		env     map[string]string
+		args    []string
+		wantErr string
+	}{
+		{
+			name:    "bad int in env",
+			env:     map[string]string{"SENTIMENT_WORKERS": "three"},
+			wantErr: "SENTIMENT_WORKERS",
+		},
+		{
+			name:    "bad duration in env",
+			env:     map[string]string{"SENTIMENT_DURATION": "5"},
+			wantErr: "SENTIMENT_DURATION",
+		},
+		{
+			name:    "bad bool in env",
+			env:     map[string]string{"SENTIMENT_ORDERED": "maybe"},
+			wantErr: "SENTIMENT_ORDERED",
+		},
+		{
+			name:    "bad pairs in env",
+			env:     map[string]string{"SENTIMENT_LANGUAGE_ANALYZERS": "fr"},
+			wantErr: "SENTIMENT_LANGUAGE_ANALYZERS",
+		},
+		{
+			name:    "bad locations in env",
+			env:     map[string]string{"SENTIMENT_LOCATIONS": "1,2,3"},
+			wantErr: "SENTIMENT_LOCATIONS",
+		},
+		{
+			name:    "unknown key in config file",
+			yaml:    "worker: 5\n",
+			wantErr: "worker",
+		},
+		{
+			name:    "wrong type in config file",
+			yaml:    "workers: many\n",
+			wantErr: "parsing config file",
+		},
+		{
+			name:    "missing config file",
+			args:    []string{"-config", "does-not-exist.yaml"},
+			wantErr: "reading config file",
+		},
+		{
+			name:    "bad flag",
+			args:    []string{"-workers", "many"},
+			wantErr: "workers",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.yaml != "" {
+				args = append([]string{"-config", writeConfig(t, tt.yaml)}, args...)
+			}
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			_, err := LoadConfig("stream", args)
+			if err == nil {
+				t.Fatal("got no error")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("got error %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeConfig writes a config file for a test and returns its path.
+func writeConfig(t *testing.T, yaml string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "sentiment.yaml")
+	if err := os.WriteFile(path, []byte(yaml), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// LiveFilter is the stream filter, whose tracked terms can be changed while
+// the stream is running.
+type LiveFilter struct {
+	mu      sync.Mutex
+	filter  Filter
+	changed chan struct{}
+}
+
+// NewLiveFilter creates a LiveFilter starting from f.
+func NewLiveFilter(f Filter) *LiveFilter {
+	return &LiveFilter{
+		filter:  f,
+		changed: make(chan struct{}),
+	}
+}
+
+// Get returns the current filter, and a channel that is closed when it
+// changes.
+func (l *LiveFilter) Get() (Filter, <-chan struct{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.filter, l.changed
+}
+
+// Update adds and removes tracked terms, returning the new filter. Terms
+// are compared case-insensitively, as Twitter matches them. The filter is
+// left alone if the result would break the streaming API's limits.
+func (l *LiveFilter) Update(add, remove []string) (Filter, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	// Build the new list of terms, keeping the existing ones in order.
+	var track []string
+	for _, term := range l.filter.Track {
+		if !containsFold(remove, term) {
+			track = append(track, term)
+		}
+	}
+	for _, term := range add {
+		term = strings.TrimSpace(term)
+		if term != "" && !containsFold(track, term) {
+			track = append(track, term)
+		}
+	}
+
+	f := l.filter
+	f.Track = track
+	if err := joinProblems(f.Validate()); err != nil {
+		return l.filter, err
+	}
+
+	// Only wake the reader if something really changed.
+	if !sameTerms(f.Track, l.filter.Track) {
+		l.filter = f
+		close(l.changed)
+		l.changed = make(chan struct{})
+	}
+	return l.filter, nil
+}
+
+// containsFold reports whether list contains s, ignoring case.
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, strings.TrimSpace(s)) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameTerms reports whether a and b are the same terms in the same order.
+func sameTerms(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
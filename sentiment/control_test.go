@@ -0,0 +1,125 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLiveFilterUpdate(t *testing.T) {
+	tests := []struct {
+		name        string
+		add         []string
+		remove      []string
+		want        []string
+		wantChanged bool
+		wantErr     bool
+	}{
+		{
+			name:        "add and remove",
+			add:         []string{"Putin"},
+			remove:      []string{"russia"},
+			want:        []string{"Trump", "Putin"},
+			wantChanged: true,
+		},
+		{
+			name:   "add a term already tracked",
+			add:    []string{" trump "},
+			want:   []string{"Trump", "Russia"},
+			remove: nil,
+		},
+		{
+			name:   "remove a term not tracked",
+			remove: []string{"Putin"},
+			want:   []string{"Trump", "Russia"},
+		},
+		{
+			name:        "blank terms are ignored",
+			add:         []string{"", " ", "Putin"},
+			want:        []string{"Trump", "Russia", "Putin"},
+			wantChanged: true,
+		},
+		{
+			name:    "removing every term",
+			remove:  []string{"Trump", "Russia"},
+			want:    []string{"Trump", "Russia"},
+			wantErr: true,
+		},
+		{
+			name:    "term too long",
+			add:     []string{strings.Repeat("x", maxTrackLength+1)},
+			want:    []string{"Trump", "Russia"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLiveFilter(Filter{Track: []string{"Trump", "Russia"}})
+			_, changed := l.Get()
+
+			f, err := l.Update(tt.add, tt.remove)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(f.Track, tt.want) {
+				t.Errorf("got terms %q, want %q", f.Track, tt.want)
+			}
+			if got, _ := l.Get(); !reflect.DeepEqual(got.Track, tt.want) {
+				t.Errorf("got live terms %q, want %q", got.Track, tt.want)
+			}
+			select {
+			case <-changed:
+				if !tt.wantChanged {
+					t.Error("got a change, want none")
+				}
+			default:
+				if tt.wantChanged {
+					t.Error("got no change")
+				}
+			}
+		})
+	}
+}
+
+func TestAPIAdminTerms(t *testing.T) {
+	tests := []struct {
+		name       string
+		token      string
+		method     string
+		body       string
+		auth       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"off without a token", "", "GET", "", "Bearer secret", http.StatusNotFound, "admin endpoints are off"},
+		{"no token", "secret", "GET", "", "", http.StatusUnauthorized, "invalid admin token"},
+		{"wrong token", "secret", "GET", "", "Bearer guess", http.StatusUnauthorized, "invalid admin token"},
+		{"list", "secret", "GET", "", "Bearer secret", http.StatusOK, `"Trump"`},
+		{"update", "secret", "POST", `{"add": ["Putin"], "remove": ["Trump"]}`, "Bearer secret", http.StatusOK, `"Putin"`},
+		{"bad update", "secret", "POST", `{"add": "Putin"}`, "Bearer secret", http.StatusBadRequest, "invalid terms update"},
+		{"invalid update", "secret", "POST", `{"remove": ["Trump"]}`, "Bearer secret", http.StatusBadRequest, "at least one term"},
+		{"delete", "secret", "DELETE", "", "Bearer secret", http.StatusMethodNotAllowed, "method not allowed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestAPI()
+			a.Filter = NewLiveFilter(Filter{Track: []string{"Trump"}})
+			a.AdminToken = tt.token
+
+			r := httptest.NewRequest(tt.method, "/api/admin/terms", strings.NewReader(tt.body))
+			if tt.auth != "" {
+				r.Header.Set("Authorization", tt.auth)
+			}
+			w := httptest.NewRecorder()
+			a.Handler().ServeHTTP(w, r)
+			if w.Code != tt.wantStatus {
+				t.Errorf("got status %d, want %d", w.Code, tt.wantStatus)
+			}
+			if !strings.Contains(w.Body.String(), tt.wantBody) {
+				t.Errorf("got body %q, want it to contain %q", w.Body.String(), tt.wantBody)
+			}
+		})
+	}
+}
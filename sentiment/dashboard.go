@@ -0,0 +1,22 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// dashboardFiles are the dashboard's static files, built into the binary
+// so the analyzer can serve its own UI.
+//
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+// dashboardHandler serves the dashboard.
+func dashboardHandler() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServer(http.FS(files))
+}
@@ -0,0 +1,181 @@
+// The dashboard loads the current stats and latest tweets from the API,
+// then keeps up to date with the live feed.
+(function () {
+  "use strict";
+
+  var maxPoints = 300;
+  var maxTweets = 100;
+
+  var history = [];
+  var lastStats = null;
+
+  function $(id) {
+    return document.getElementById(id);
+  }
+
+  // Summary cards.
+  function showTotals(total) {
+    $("sentiment").textContent = total.counts.total ? total.sentiment_average.toFixed(2) : "–";
+    $("total").textContent = total.counts.total;
+  }
+
+  // Sentiment over time.
+  function drawHistory() {
+    var canvas = $("history");
+    var width = canvas.width = canvas.clientWidth * window.devicePixelRatio;
+    var height = canvas.height = 200 * window.devicePixelRatio;
+    var ctx = canvas.getContext("2d");
+    ctx.clearRect(0, 0, width, height);
+
+    // Gridlines at the label thresholds.
+    ctx.strokeStyle = "#e3e5e8";
+    ctx.lineWidth = 1;
+    [0.5, 0.8].forEach(function (v) {
+      var y = height - v * height;
+      ctx.beginPath();
+      ctx.moveTo(0, y);
+      ctx.lineTo(width, y);
+      ctx.stroke();
+    });
+
+    if (history.length < 2) {
+      return;
+    }
+    ctx.strokeStyle = "#1d2b3a";
+    ctx.lineWidth = 2 * window.devicePixelRatio;
+    ctx.beginPath();
+    history.forEach(function (v, i) {
+      var x = i / (maxPoints - 1) * width;
+      var y = height - v * height;
+      if (i === 0) {
+        ctx.moveTo(x, y);
+      } else {
+        ctx.lineTo(x, y);
+      }
+    });
+    ctx.stroke();
+  }
+
+  // Positive, neutral and negative split per term.
+  function showTerms(terms) {
+    var container = $("terms");
+    container.textContent = "";
+    Object.keys(terms || {}).sort().forEach(function (name) {
+      var counts = terms[name].counts;
+      var row = document.createElement("div");
+      row.className = "term";
+
+      var label = document.createElement("span");
+      label.className = "name";
+      label.textContent = name;
+      row.appendChild(label);
+
+      var bar = document.createElement("div");
+      bar.className = "bar";
+      ["positive", "neutral", "negative"].forEach(function (kind) {
+        var part = document.createElement("div");
+        part.className = kind;
+        part.style.width = (counts.total ? 100 * counts[kind] / counts.total : 0) + "%";
+        part.title = counts[kind] + " " + kind;
+        bar.appendChild(part);
+      });
+      row.appendChild(bar);
+
+      var count = document.createElement("span");
+      count.className = "count";
+      count.textContent = counts.total + " tweets, " + terms[name].sentiment_average.toFixed(2);
+      row.appendChild(count);
+
+      container.appendChild(row);
+    });
+  }
+
+  // Latest tweets, newest at the top.
+  function addTweet(t, atEnd) {
+    var list = $("tweets");
+    var item = document.createElement("li");
+    item.className = t.label;
+
+    var text = document.createElement("div");
+    text.textContent = t.text;
+    item.appendChild(text);
+
+    var meta = document.createElement("div");
+    meta.className = "meta";
+    meta.textContent = t.label + " (" + t.sentiment.toFixed(2) + ")" +
+      (t.terms && t.terms.length ? " · " + t.terms.join(", ") : "") +
+      (t.lang ? " · " + t.lang : "");
+    item.appendChild(meta);
+
+    if (atEnd) {
+      list.appendChild(item);
+    } else {
+      list.insertBefore(item, list.firstChild);
+    }
+    while (list.children.length > maxTweets) {
+      list.removeChild(list.lastChild);
+    }
+  }
+
+  function onStats(d) {
+    showTotals(d.total);
+    if (d.total.counts.total) {
+      history.push(d.total.sentiment_average);
+      if (history.length > maxPoints) {
+        history.shift();
+      }
+    }
+    drawHistory();
+
+    var terms = {};
+    Object.keys(d.terms || {}).forEach(function (name) {
+      terms[name] = d.terms[name].total;
+    });
+    showTerms(terms);
+
+    if (lastStats) {
+      var seconds = (new Date(d.time) - new Date(lastStats.time)) / 1000;
+      if (seconds > 0) {
+        $("rate").textContent = (d.new.counts.total / seconds).toFixed(1);
+      }
+    }
+    lastStats = d;
+  }
+
+  function getJSON(url, then) {
+    fetch(url).then(function (resp) {
+      return resp.json();
+    }).then(then).catch(function (err) {
+      console.error(url, err);
+    });
+  }
+
+  // Start from the current stats and tweets.
+  getJSON("api/stats", function (s) {
+    showTotals(s);
+    showTerms(s.terms);
+  });
+  getJSON("api/tweets?n=" + maxTweets, function (tweets) {
+    tweets.forEach(function (t) {
+      addTweet({text: t.text, label: t.label, sentiment: t.sentiment, terms: t.terms, lang: t.lang}, true);
+    });
+  });
+
+  // Then follow the feed. EventSource reconnects by itself.
+  var feed = new EventSource("api/feed");
+  feed.onopen = function () {
+    $("status").textContent = "live";
+  };
+  feed.onerror = function () {
+    $("status").textContent = "reconnecting…";
+  };
+  feed.addEventListener("tweet", function (e) {
+    addTweet(JSON.parse(e.data), false);
+  });
+  feed.addEventListener("stats", function (e) {
+    onStats(JSON.parse(e.data));
+  });
+
+  window.addEventListener("resize", drawHistory);
+  drawHistory();
+})();
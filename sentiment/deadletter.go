@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"sync"
+	"time"
+)
+
+// DeadLetter is a tweet that could not be analyzed.
+type DeadLetter struct {
+	Tweet    Tweet     `json:"tweet"`
+	Error    string    `json:"error"`
+	Attempts int       `json:"attempts"`
+	Time     time.Time `json:"time"`
+}
+
+// DeadLetterSink appends dead letters to a JSONL file.
+type DeadLetterSink struct {
+	file *os.File
+	enc  *json.Encoder
+	mux  sync.Mutex
+}
+
+// OpenDeadLetterSink opens (or creates) the dead-letter file at path for
+// appending.
+func OpenDeadLetterSink(path string) (*DeadLetterSink, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &DeadLetterSink{
+		file: f,
+		enc:  json.NewEncoder(f),
+	}, nil
+}
+
+// Write records a tweet that failed analysis. The number of attempts is
+// taken from the error if it carries one.
+func (d *DeadLetterSink) Write(t Tweet, err error) error {
+	return d.WriteLetter(DeadLetter{
+		Tweet:    t,
+		Error:    err.Error(),
+		Attempts: attempts(err),
+		Time:     time.Now().UTC(),
+	})
+}
+
+// WriteLetter records a dead letter as is.
+func (d *DeadLetterSink) WriteLetter(l DeadLetter) error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.enc.Encode(l)
+}
+
+// Close closes the dead-letter file.
+func (d *DeadLetterSink) Close() error {
+	d.mux.Lock()
+	defer d.mux.Unlock()
+	return d.file.Close()
+}
+
+// ReadDeadLetters reads all of the dead letters in the file at path. A
+// missing file has no dead letters.
+func ReadDeadLetters(path string) ([]DeadLetter, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var l DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &l); err != nil {
+			return nil, err
+		}
+		letters = append(letters, l)
+	}
+	return letters, scanner.Err()
+}
+
+// attempts returns the number of attempts recorded in an error.
+func attempts(err error) int {
+	var attemptErr *AttemptError
+	if errors.As(err, &attemptErr) {
+		return attemptErr.Attempts
+	}
+	return 1
+}
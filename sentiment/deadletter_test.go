@@ -0,0 +1,93 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReplayDeadLetters(t *testing.T) {
+	unavailable := errors.New("503 Service Unavailable")
+	tests := []struct {
+		name         string
+		errs         []error
+		wantTotal    int
+		wantLetters  int
+		wantAttempts int
+	}{
+		{"scored", nil, 2, 1, 1},
+		{"failing again", []error{unavailable, unavailable}, 0, 3, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			deadLetterPath := filepath.Join(dir, "deadletter.jsonl")
+			statsPath := filepath.Join(dir, "stats.json")
+
+			// Dead-letter two English tweets and one we don't analyze.
+			sink, err := OpenDeadLetterSink(deadLetterPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, tw := range []Tweet{
+				{ID: "1", Text: "I love this", Lang: "en"},
+				{ID: "2", Text: "I hate this", Lang: "en"},
+				{ID: "3", Text: "J'adore ça", Lang: "fr"},
+			} {
+				if err := sink.Write(tw, unavailable); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := sink.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			a := &fakeAnalyzer{errs: tt.errs}
+			p := &Pipeline{
+				Router: &LanguageRouter{
+					Default: NewResilientAnalyzer(a, nil, Policy{FailureThreshold: 10, OpenTimeout: time.Minute}),
+					Allowed: map[string]bool{"en": true},
+				},
+			}
+			if err := replayDeadLetters(io.Discard, p, deadLetterPath, statsPath); err != nil {
+				t.Fatal(err)
+			}
+
+			stats, err := LoadStats(statsPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := stats.Counts["total"]; got != tt.wantTotal {
+				t.Errorf("got %d tweets in the stats, want %d", got, tt.wantTotal)
+			}
+			letters, err := ReadDeadLetters(deadLetterPath)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(letters) != tt.wantLetters {
+				t.Fatalf("got %d dead letters, want %d", len(letters), tt.wantLetters)
+			}
+			for _, l := range letters {
+				want := tt.wantAttempts
+				if l.Tweet.Lang == "fr" {
+					want = 1
+				}
+				if l.Attempts != want {
+					t.Errorf("tweet %s: got %d attempts, want %d", l.Tweet.ID, l.Attempts, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadDeadLettersMissing(t *testing.T) {
+	letters, err := ReadDeadLetters(filepath.Join(t.TempDir(), "deadletter.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 0 {
+		t.Errorf("got %d dead letters, want none", len(letters))
+	}
+}
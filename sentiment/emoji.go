@@ -0,0 +1,174 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// EmojiSignal is the sentiment carried by the emoji and emoticons in a
+// tweet, separate from what the analyzer makes of the text.
+type EmojiSignal struct {
+
+	// Score is on the same scale as Sentiment, from 0 (negative) to 1
+	// (positive). It is 0.5 if the tweet has no emoji we know.
+	Score float64 `json:"score"`
+
+	// Emoji are the emoji and emoticons found, in order.
+	Emoji []string `json:"emoji,omitempty"`
+}
+
+// emojiSentiment is the sentiment of common emoji and emoticons, from -1
+// (negative) to 1 (positive). The emoji scores are rounded from the Emoji
+// Sentiment Ranking (Kralj Novak et al., 2015), which was built from
+// hand-labelled tweets; the emoticons are scored like the emoji they
+// stand for.
+var emojiSentiment = map[string]float64{
+	"😀": 0.57,
+	"😁": 0.45,
+	"😂": 0.22,
+	"🤣": 0.25,
+	"😃": 0.56,
+	"😄": 0.55,
+	"😅": 0.18,
+	"😆": 0.40,
+	"😉": 0.46,
+	"😊": 0.66,
+	"😍": 0.68,
+	"😘": 0.70,
+	"🥰": 0.70,
+	"🙂": 0.45,
+	"☺": 0.66,
+	"😎": 0.49,
+	"😇": 0.52,
+	"🤗": 0.55,
+	"🤔": 0.05,
+	"😐": -0.06,
+	"😑": -0.12,
+	"🙄": -0.30,
+	"😏": 0.33,
+	"😒": -0.38,
+	"😔": -0.26,
+	"😕": -0.33,
+	"🙁": -0.40,
+	"😞": -0.45,
+	"😟": -0.40,
+	"😢": -0.17,
+	"😭": -0.09,
+	"😤": -0.20,
+	"😠": -0.52,
+	"😡": -0.57,
+	"🤬": -0.65,
+	"😱": -0.14,
+	"😨": -0.35,
+	"😩": -0.37,
+	"😫": -0.31,
+	"😳": 0.02,
+	"🤮": -0.60,
+	"🤢": -0.50,
+	"💩": -0.12,
+	"🤡": -0.25,
+	"👍": 0.52,
+	"👎": -0.45,
+	"👌": 0.56,
+	"👏": 0.52,
+	"🙌": 0.55,
+	"🙏": 0.42,
+	"💪": 0.56,
+	"🔥": 0.35,
+	"💯": 0.49,
+	"🎉": 0.67,
+	"✨": 0.52,
+	"⭐": 0.45,
+	"❤": 0.75,
+	"♥": 0.68,
+	"💕": 0.63,
+	"💖": 0.71,
+	"💙": 0.67,
+	"💔": -0.12,
+	"✅": 0.45,
+	"❌": -0.35,
+	"⚠": -0.30,
+	"🚨": -0.15,
+
+	":)":  0.66,
+	":-)": 0.66,
+	"(:":  0.66,
+	":D":  0.55,
+	":-D": 0.55,
+	";)":  0.46,
+	";-)": 0.46,
+	":P":  0.40,
+	":-P": 0.40,
+	"<3":  0.75,
+	":(":  -0.40,
+	":-(": -0.40,
+	"):":  -0.40,
+	":'(": -0.17,
+	":/":  -0.33,
+	":-/": -0.33,
+	":|":  -0.06,
+	">:(": -0.52,
+	"</3": -0.12,
+}
+
+// ScoreEmoji scores the emoji and emoticons in the text. Emoji are found
+// anywhere in the text; emoticons only as words of their own, so that
+// URLs like "https://..." don't count as ":/".
+func ScoreEmoji(text string) EmojiSignal {
+	signal := EmojiSignal{Score: 0.5}
+	total := 0.0
+	add := func(e string, score float64) {
+		signal.Emoji = append(signal.Emoji, e)
+		total += score
+	}
+	for _, word := range strings.Fields(text) {
+		emoticon := strings.TrimRight(word, ".,!?")
+		if score, ok := emojiSentiment[emoticon]; ok && emoticon[0] < utf8.RuneSelf {
+			add(emoticon, score)
+			continue
+		}
+		for _, r := range word {
+			if score, ok := emojiSentiment[string(r)]; ok && r >= utf8.RuneSelf {
+				add(string(r), score)
+			}
+		}
+	}
+	if len(signal.Emoji) > 0 {
+		signal.Score = (total/float64(len(signal.Emoji)) + 1) / 2
+	}
+	return signal
+}
+
+// blendSentiment mixes the emoji signal into the text sentiment, giving it
+// weight (0 to 1) when the tweet has any emoji.
+func blendSentiment(text float64, emoji EmojiSignal, weight float64) float64 {
+	if len(emoji.Emoji) == 0 || weight <= 0 {
+		return text
+	}
+	return (1-weight)*text + weight*emoji.Score
+}
+
+// EmojiCount is how many times an emoji was used.
+type EmojiCount struct {
+	Emoji string `json:"emoji"`
+	Count int    `json:"count"`
+}
+
+// topEmoji returns the n most used emoji in counts, most used first.
+func topEmoji(counts map[string]int, n int) []EmojiCount {
+	top := make([]EmojiCount, 0, len(counts))
+	for e, c := range counts {
+		top = append(top, EmojiCount{e, c})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Emoji < top[j].Emoji
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
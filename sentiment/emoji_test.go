@@ -0,0 +1,62 @@
+package main
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestScoreEmoji(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		wantEmoji []string
+		wantScore float64
+	}{
+		{"no emoji", "just some words", nil, 0.5},
+		{"unknown emoji", "a 🦖 appears", nil, 0.5},
+		{"one emoji", "I love it 😍", []string{"😍"}, (0.68 + 1) / 2},
+		{"emoji averaged", "😍😡", []string{"😍", "😡"}, (0.68 - 0.57 + 2) / 4},
+		{"emoji inside a word", "yes👍", []string{"👍"}, (0.52 + 1) / 2},
+		{"emoticon", "see you soon :)", []string{":)"}, (0.66 + 1) / 2},
+		{"emoticon with punctuation", "oh no :(!", []string{":("}, (-0.40 + 1) / 2},
+		{"emoticon inside a url", "https://example.com", nil, 0.5},
+		{"skin tone", "ok 👍🏽", []string{"👍"}, (0.52 + 1) / 2},
+		{"variation selector", "love ❤️", []string{"❤"}, (0.75 + 1) / 2},
+		{"zwj sequence of known emoji", "❤️‍🔥", []string{"❤", "🔥"}, (0.75 + 0.35 + 2) / 4},
+		{"zwj sequence of unknown emoji", "👨‍👩‍👧", nil, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ScoreEmoji(tt.text)
+			if !reflect.DeepEqual(got.Emoji, tt.wantEmoji) {
+				t.Errorf("got emoji %q, want %q", got.Emoji, tt.wantEmoji)
+			}
+			if math.Abs(got.Score-tt.wantScore) > 1e-9 {
+				t.Errorf("got score %v, want %v", got.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestBlendSentiment(t *testing.T) {
+	positive := EmojiSignal{Score: 0.9, Emoji: []string{"😍"}}
+	tests := []struct {
+		name   string
+		emoji  EmojiSignal
+		weight float64
+		want   float64
+	}{
+		{"weight 0", positive, 0, 0.2},
+		{"weight 1", positive, 1, 0.9},
+		{"halfway", positive, 0.5, 0.55},
+		{"no emoji", EmojiSignal{Score: 0.5}, 1, 0.2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := blendSentiment(0.2, tt.emoji, tt.weight); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
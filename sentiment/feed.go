@@ -0,0 +1,278 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+
+	// feedBuffer is how many events are buffered for each subscriber. A
+	// subscriber that falls this far behind is disconnected.
+	feedBuffer = 256
+
+	// feedWriteTimeout is how long writing an event to a subscriber may
+	// take before they are disconnected.
+	feedWriteTimeout = 10 * time.Second
+
+	// feedHeartbeat is how often an idle connection gets a comment, to
+	// keep proxies from closing it.
+	feedHeartbeat = 15 * time.Second
+)
+
+// FeedTweet is a scored tweet as sent to feed subscribers.
+type FeedTweet struct {
+	ID        string   `json:"id"`
+	Seq       uint64   `json:"seq"`
+	Text      string   `json:"text"`
+	Lang      string   `json:"lang,omitempty"`
+	Terms     []string `json:"terms,omitempty"`
+	Sentiment float64  `json:"sentiment"`
+	Label     string   `json:"label"`
+}
+
+// StatsDelta is what changed in the stats since the last delta.
+type StatsDelta struct {
+	Time time.Time `json:"time"`
+
+	// Total is the stats so far, and New the tweets added since the last
+	// delta.
+	Total Breakdown `json:"total"`
+	New   Breakdown `json:"new"`
+
+	// Terms is the same for each term.
+	Terms map[string]TermDelta `json:"terms,omitempty"`
+}
+
+// TermDelta is what changed in the stats for a term.
+type TermDelta struct {
+	Total Breakdown `json:"total"`
+	New   Breakdown `json:"new"`
+}
+
+// feedEvent is an event for subscribers: a tweet or a stats delta.
+type feedEvent struct {
+	tweet *FeedTweet
+	stats *StatsDelta
+}
+
+// feedClient is a single subscriber.
+type feedClient struct {
+	events chan feedEvent
+
+	// terms are the lower-cased terms the subscriber wants, or nil for all.
+	terms map[string]bool
+
+	// gone is closed when the subscriber is dropped for being too slow.
+	gone chan struct{}
+}
+
+// Feed pushes scored tweets and stats deltas to subscribers as
+// Server-Sent Events. Each subscriber has its own buffer, and subscribers
+// that can't keep up are disconnected rather than holding up the others.
+// It is safe for concurrent use.
+type Feed struct {
+	mux     sync.Mutex
+	clients map[*feedClient]bool
+}
+
+// NewFeed creates a Feed with no subscribers.
+func NewFeed() *Feed {
+	return &Feed{clients: make(map[*feedClient]bool)}
+}
+
+// PublishTweet sends a scored tweet to the subscribers.
+func (f *Feed) PublishTweet(t ScoredTweet) {
+	f.publish(feedEvent{tweet: &FeedTweet{
+		ID:        t.ID,
+		Seq:       t.Seq,
+		Text:      t.Text,
+		Lang:      t.Lang,
+		Terms:     t.Terms,
+		Sentiment: t.Sentiment,
+		Label:     t.Label,
+	}})
+}
+
+// PublishStats sends a stats delta to the subscribers.
+func (f *Feed) PublishStats(d StatsDelta) {
+	f.publish(feedEvent{stats: &d})
+}
+
+// publish sends an event to every subscriber, dropping those whose buffer
+// is full.
+func (f *Feed) publish(e feedEvent) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+
+	for c := range f.clients {
+		select {
+		case c.events <- e:
+		default:
+			delete(f.clients, c)
+			close(c.gone)
+		}
+	}
+}
+
+// subscribe adds a subscriber.
+func (f *Feed) subscribe(terms map[string]bool) *feedClient {
+	c := &feedClient{
+		events: make(chan feedEvent, feedBuffer),
+		terms:  terms,
+		gone:   make(chan struct{}),
+	}
+	f.mux.Lock()
+	f.clients[c] = true
+	f.mux.Unlock()
+	return c
+}
+
+// unsubscribe removes a subscriber, if it is still there.
+func (f *Feed) unsubscribe(c *feedClient) {
+	f.mux.Lock()
+	delete(f.clients, c)
+	f.mux.Unlock()
+}
+
+// ServeHTTP streams events to a subscriber until they disconnect or fall
+// behind. "tweet" events are scored tweets and "stats" events are stats
+// deltas. The terms query parameter (comma-separated) limits both to
+// the given terms.
+func (f *Feed) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var terms map[string]bool
+	if v := r.URL.Query().Get("terms"); v != "" {
+		terms = make(map[string]bool)
+		for _, term := range splitList(v) {
+			terms[strings.ToLower(term)] = true
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+
+	c := f.subscribe(terms)
+	defer f.unsubscribe(c)
+
+	heartbeat := time.NewTicker(feedHeartbeat)
+	defer heartbeat.Stop()
+
+	// send writes a chunk and flushes it, reporting false if the
+	// subscriber has gone.
+	send := func(chunk string) bool {
+		rc.SetWriteDeadline(time.Now().Add(feedWriteTimeout))
+		if _, err := fmt.Fprint(w, chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	if !send(": connected\n\n") {
+		return
+	}
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-c.gone:
+			return
+		case <-heartbeat.C:
+			if !send(": heartbeat\n\n") {
+				return
+			}
+		case e := <-c.events:
+			name, v := c.filter(e)
+			if v == nil {
+				continue
+			}
+			data, err := json.Marshal(v)
+			if err != nil {
+				fmt.Println("Error encoding feed event:", err)
+				continue
+			}
+			if !send(fmt.Sprintf("event: %s\ndata: %s\n\n", name, data)) {
+				return
+			}
+		}
+	}
+}
+
+// filter returns the name and payload of an event as this subscriber
+// should see it, or nil if they shouldn't see it at all.
+func (c *feedClient) filter(e feedEvent) (string, interface{}) {
+	switch {
+	case e.tweet != nil:
+		if c.terms == nil {
+			return "tweet", e.tweet
+		}
+		for _, term := range e.tweet.Terms {
+			if c.terms[strings.ToLower(term)] {
+				return "tweet", e.tweet
+			}
+		}
+		return "", nil
+
+	case e.stats != nil:
+		if c.terms == nil {
+			return "stats", e.stats
+		}
+		d := *e.stats
+		d.Terms = make(map[string]TermDelta)
+		for term, td := range e.stats.Terms {
+			if c.terms[strings.ToLower(term)] {
+				d.Terms[term] = td
+			}
+		}
+		return "stats", d
+	}
+	return "", nil
+}
+
+// statsDelta works out what changed between two snapshots of the stats.
+// prev may be nil for the first delta.
+func statsDelta(prev, cur *Stats, now time.Time) StatsDelta {
+	if prev == nil {
+		prev = NewStats()
+	}
+	d := StatsDelta{
+		Time:  now,
+		Total: cur.Breakdown,
+		New:   breakdownDelta(&prev.Breakdown, &cur.Breakdown),
+		Terms: make(map[string]TermDelta, len(cur.Terms)),
+	}
+	for term, b := range cur.Terms {
+		p, ok := prev.Terms[term]
+		if !ok {
+			p = NewBreakdown()
+		}
+		d.Terms[term] = TermDelta{Total: *b, New: breakdownDelta(p, b)}
+	}
+	return d
+}
+
+// breakdownDelta returns the tweets in cur that weren't in prev, where cur
+// is a later version of prev.
+func breakdownDelta(prev, cur *Breakdown) Breakdown {
+	d := *NewBreakdown()
+	for k, v := range cur.Counts {
+		d.Counts[k] = v - prev.Counts[k]
+	}
+	d.SentimentAverage, d.Weight = removeWeighted(cur.SentimentAverage, cur.weight(), prev.SentimentAverage, prev.weight())
+	d.WeightedAverage, d.InfluenceWeight = removeWeighted(cur.WeightedAverage, cur.InfluenceWeight, prev.WeightedAverage, prev.InfluenceWeight)
+	return d
+}
+
+// removeWeighted removes the values with average value and total weight w
+// from an average with total weight total, the opposite of addWeighted.
+func removeWeighted(average, total, value, w float64) (float64, float64) {
+	if total-w <= 1e-9 {
+		return 0, 0
+	}
+	return (average*total - value*w) / (total - w), total - w
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFeedFilter(t *testing.T) {
+	trump := feedEvent{tweet: &FeedTweet{ID: "1", Terms: []string{"Trump"}}}
+	both := feedEvent{tweet: &FeedTweet{ID: "2", Terms: []string{"Russia", "Trump"}}}
+	russia := feedEvent{tweet: &FeedTweet{ID: "3", Terms: []string{"Russia"}}}
+	stats := feedEvent{stats: &StatsDelta{Terms: map[string]TermDelta{"Trump": {}, "Russia": {}}}}
+	tests := []struct {
+		name      string
+		terms     map[string]bool
+		event     feedEvent
+		wantName  string
+		wantTerms []string
+	}{
+		{"all tweets", nil, russia, "tweet", nil},
+		{"tweet about the term", map[string]bool{"trump": true}, trump, "tweet", nil},
+		{"tweet about several terms", map[string]bool{"trump": true}, both, "tweet", nil},
+		{"tweet about another term", map[string]bool{"trump": true}, russia, "", nil},
+		{"all stats", nil, stats, "stats", []string{"Russia", "Trump"}},
+		{"stats for the term", map[string]bool{"trump": true}, stats, "stats", []string{"Trump"}},
+		{"stats for no terms", map[string]bool{"putin": true}, stats, "stats", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &feedClient{terms: tt.terms}
+			name, v := c.filter(tt.event)
+			if name != tt.wantName {
+				t.Fatalf("got event %q, want %q", name, tt.wantName)
+			}
+			var d StatsDelta
+			switch v := v.(type) {
+			case StatsDelta:
+				d = v
+			case *StatsDelta:
+				d = *v
+			default:
+				return
+			}
+			var terms []string
+			for term := range d.Terms {
+				terms = append(terms, term)
+			}
+			sort.Strings(terms)
+			if !reflect.DeepEqual(terms, tt.wantTerms) {
+				t.Errorf("got stats for %v, want %v", terms, tt.wantTerms)
+			}
+		})
+	}
+}
+
+func TestFeedServeHTTP(t *testing.T) {
+	f := NewFeed()
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "?terms=trump")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("got content type %q, want text/event-stream", ct)
+	}
+
+	// The subscriber is added before the first comment is sent.
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	if got := <-lines; got != ": connected" {
+		t.Fatalf("got %q, want the connected comment", got)
+	}
+
+	var russia, trump ScoredTweet
+	russia.ID, russia.Terms = "1", []string{"Russia"}
+	trump.ID, trump.Terms = "2", []string{"Trump"}
+	f.PublishTweet(russia)
+	f.PublishTweet(trump)
+
+	var got []string
+	timeout := time.After(time.Second)
+	for len(got) < 2 {
+		select {
+		case line := <-lines:
+			if line != "" {
+				got = append(got, line)
+			}
+		case <-timeout:
+			t.Fatalf("got %q, then nothing", got)
+		}
+	}
+	if got[0] != "event: tweet" || !strings.Contains(got[1], `"id":"2"`) {
+		t.Errorf("got %q, want only the tweet about Trump", got)
+	}
+}
+
+func TestFeedDropsSlowSubscribers(t *testing.T) {
+	f := NewFeed()
+	slow := f.subscribe(nil)
+	fast := f.subscribe(nil)
+
+	var st ScoredTweet
+	for i := 0; i <= feedBuffer; i++ {
+		f.PublishTweet(st)
+		if i < feedBuffer {
+			<-fast.events
+		}
+	}
+
+	select {
+	case <-slow.gone:
+	default:
+		t.Error("slow subscriber wasn't dropped")
+	}
+	select {
+	case <-fast.gone:
+		t.Error("fast subscriber was dropped")
+	default:
+	}
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.clients[slow] || !f.clients[fast] {
+		t.Errorf("got subscribers %v, want only the fast one", f.clients)
+	}
+}
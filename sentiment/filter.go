@@ -0,0 +1,207 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits Twitter puts on a single statuses/filter connection.
+const (
+	maxTrackTerms  = 400
+	maxTrackLength = 60
+	maxFollowIDs   = 5000
+	maxLocations   = 25
+)
+
+// Names of the filter predicates a tweet can match.
+const (
+	matchTrack    = "track"
+	matchFollow   = "follow"
+	matchLocation = "location"
+)
+
+// BoundingBox is a geographic bounding box, given as the longitude and
+// latitude of its south-west corner followed by those of its north-east
+// corner (the order Twitter uses).
+type BoundingBox [4]float64
+
+// Contains reports whether the point is inside the box.
+func (b BoundingBox) Contains(lng, lat float64) bool {
+	return lng >= b[0] && lng <= b[2] && lat >= b[1] && lat <= b[3]
+}
+
+// validate checks that the box is a real box on the globe.
+func (b BoundingBox) validate() error {
+	switch {
+	case b[0] < -180 || b[0] > 180 || b[2] < -180 || b[2] > 180:
+		return fmt.Errorf("bounding box %v: longitudes must be between -180 and 180", b)
+	case b[1] < -90 || b[1] > 90 || b[3] < -90 || b[3] > 90:
+		return fmt.Errorf("bounding box %v: latitudes must be between -90 and 90", b)
+	case b[0] >= b[2] || b[1] >= b[3]:
+		return fmt.Errorf("bounding box %v: the south-west corner must come first", b)
+	}
+	return nil
+}
+
+// ParseLocations parses a comma-separated list of coordinates, four per
+// bounding box, as accepted by Twitter's locations parameter.
+func ParseLocations(s string) ([]BoundingBox, error) {
+	coords := splitList(s)
+	if len(coords)%4 != 0 {
+		return nil, fmt.Errorf("locations %q: need four coordinates per bounding box, got %d", s, len(coords))
+	}
+
+	boxes := make([]BoundingBox, len(coords)/4)
+	for i, c := range coords {
+		v, err := strconv.ParseFloat(c, 64)
+		if err != nil {
+			return nil, fmt.Errorf("locations %q: %q is not a coordinate", s, c)
+		}
+		boxes[i/4][i%4] = v
+	}
+	return boxes, nil
+}
+
+// Filter is what we ask the statuses/filter endpoint for. Twitter ORs the
+// predicates together, so a tweet may arrive because of any one of them.
+type Filter struct {
+	Track     []string
+	Follow    []string
+	Locations []BoundingBox
+
+	// StrictLocations drops tweets that only match on location but whose
+	// coordinates fall outside every box. Twitter also matches tweets whose
+	// place merely overlaps a box.
+	StrictLocations bool
+}
+
+// Validate checks the filter against the limits of the streaming API.
+func (f Filter) Validate() []string {
+	var problems []string
+	if len(f.Track) == 0 && len(f.Follow) == 0 && len(f.Locations) == 0 {
+		problems = append(problems, "at least one term, user ID or location to filter on is required, e.g. -terms Trump,Russia")
+	}
+	if len(f.Track) > maxTrackTerms {
+		problems = append(problems, fmt.Sprintf("at most %d terms can be tracked, not %d", maxTrackTerms, len(f.Track)))
+	}
+	for _, term := range f.Track {
+		if len(term) > maxTrackLength {
+			problems = append(problems, fmt.Sprintf("term %q is longer than %d bytes", term, maxTrackLength))
+		}
+	}
+	if len(f.Follow) > maxFollowIDs {
+		problems = append(problems, fmt.Sprintf("at most %d user IDs can be followed, not %d", maxFollowIDs, len(f.Follow)))
+	}
+	for _, id := range f.Follow {
+		if _, err := strconv.ParseUint(id, 10, 64); err != nil {
+			problems = append(problems, fmt.Sprintf("follow %q is not a numeric user ID", id))
+		}
+	}
+	if len(f.Locations) > maxLocations {
+		problems = append(problems, fmt.Sprintf("at most %d locations can be used, not %d", maxLocations, len(f.Locations)))
+	}
+	for _, b := range f.Locations {
+		if err := b.validate(); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	return problems
+}
+
+// Values returns the form values for the filter request.
+func (f Filter) Values() url.Values {
+	form := url.Values{}
+	if len(f.Track) > 0 {
+		form.Set("track", strings.Join(f.Track, ","))
+	}
+	if len(f.Follow) > 0 {
+		form.Set("follow", strings.Join(f.Follow, ","))
+	}
+	if len(f.Locations) > 0 {
+		var coords []string
+		for _, b := range f.Locations {
+			for _, c := range b {
+				coords = append(coords, strconv.FormatFloat(c, 'f', -1, 64))
+			}
+		}
+		form.Set("locations", strings.Join(coords, ","))
+	}
+	return form
+}
+
+// Match works out which of the filter's predicates a tweet matches,
+// setting its Terms and Matched fields. It reports false if the tweet
+// should be dropped.
+func (f Filter) Match(t *Tweet) bool {
+	t.Terms = nil
+	t.Matched = nil
+
+	words := wordSet(t.Text)
+	for _, term := range f.Track {
+		if matchesTerm(words, term) {
+			t.Terms = append(t.Terms, term)
+		}
+	}
+	if len(t.Terms) > 0 {
+		t.Matched = append(t.Matched, matchTrack)
+	}
+
+	// Following a user gets their tweets and replies to them.
+	for _, id := range f.Follow {
+		if t.User.ID == id || t.InReplyToUserID == id {
+			t.Matched = append(t.Matched, matchFollow)
+			break
+		}
+	}
+
+	// Only count a location match if the tweet is really inside a box.
+	if len(f.Locations) > 0 {
+		if lng, lat, ok := t.Location(); ok {
+			for _, b := range f.Locations {
+				if b.Contains(lng, lat) {
+					t.Matched = append(t.Matched, matchLocation)
+					break
+				}
+			}
+		}
+	}
+
+	return !f.StrictLocations || len(f.Locations) == 0 || len(t.Matched) > 0
+}
+
+// matchesTerm reports whether a text, split up by wordSet, mentions a
+// tracked term. Like Twitter, terms match whole words case-insensitively,
+// so "russia" matches "#Russia" and "russia's" but not "Russian", and a
+// term with spaces matches if all of its words are in the text.
+func matchesTerm(words map[string]bool, term string) bool {
+	termWords := splitWords(term)
+	if len(termWords) == 0 {
+		return false
+	}
+	for _, word := range termWords {
+		if !words[word] {
+			return false
+		}
+	}
+	return true
+}
+
+// wordSet returns the set of lower-cased words in text.
+func wordSet(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, word := range splitWords(text) {
+		words[word] = true
+	}
+	return words
+}
+
+// splitWords splits text into lower-cased words at anything that isn't a
+// letter or a digit, the way Twitter splits tweets up to match terms.
+func splitWords(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
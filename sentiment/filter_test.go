@@ -0,0 +1,128 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	nyc := BoundingBox{-74.3, 40.5, -73.7, 40.9}
+	tests := []struct {
+		name        string
+		filter      Filter
+		tweet       Tweet
+		wantTerms   []string
+		wantMatched []string
+		wantKeep    bool
+	}{
+		{
+			name:        "term",
+			filter:      Filter{Track: []string{"trump", "russia"}},
+			tweet:       Tweet{Text: "Trump talks about #Russia's role"},
+			wantTerms:   []string{"trump", "russia"},
+			wantMatched: []string{matchTrack},
+			wantKeep:    true,
+		},
+		{
+			name:     "whole words only",
+			filter:   Filter{Track: []string{"russia"}},
+			tweet:    Tweet{Text: "a Russian spy"},
+			wantKeep: true,
+		},
+		{
+			name:        "phrase matches all its words",
+			filter:      Filter{Track: []string{"climate change"}},
+			tweet:       Tweet{Text: "the change in the climate"},
+			wantTerms:   []string{"climate change"},
+			wantMatched: []string{matchTrack},
+			wantKeep:    true,
+		},
+		{
+			name:     "phrase missing a word",
+			filter:   Filter{Track: []string{"climate change"}},
+			tweet:    Tweet{Text: "the climate today"},
+			wantKeep: true,
+		},
+		{
+			name:        "follow matches replies",
+			filter:      Filter{Follow: []string{"42"}},
+			tweet:       Tweet{Text: "hi", InReplyToUserID: "42"},
+			wantMatched: []string{matchFollow},
+			wantKeep:    true,
+		},
+		{
+			name:        "location inside a box",
+			filter:      Filter{Locations: []BoundingBox{nyc}, StrictLocations: true},
+			tweet:       Tweet{Text: "hi", Coordinates: &Point{Coordinates: [2]float64{-74, 40.7}}},
+			wantMatched: []string{matchLocation},
+			wantKeep:    true,
+		},
+		{
+			name:     "strict location outside every box",
+			filter:   Filter{Locations: []BoundingBox{nyc}, StrictLocations: true},
+			tweet:    Tweet{Text: "hi", Coordinates: &Point{Coordinates: [2]float64{2.35, 48.85}}},
+			wantKeep: false,
+		},
+		{
+			name:     "loose location outside every box",
+			filter:   Filter{Locations: []BoundingBox{nyc}},
+			tweet:    Tweet{Text: "hi", Coordinates: &Point{Coordinates: [2]float64{2.35, 48.85}}},
+			wantKeep: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tweet := tt.tweet
+			keep := tt.filter.Match(&tweet)
+			if keep != tt.wantKeep {
+				t.Errorf("Match() = %v, want %v", keep, tt.wantKeep)
+			}
+			if !reflect.DeepEqual(tweet.Terms, tt.wantTerms) {
+				t.Errorf("got terms %q, want %q", tweet.Terms, tt.wantTerms)
+			}
+			if !reflect.DeepEqual(tweet.Matched, tt.wantMatched) {
+				t.Errorf("got matched %q, want %q", tweet.Matched, tt.wantMatched)
+			}
+		})
+	}
+}
+
+func TestFilterValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		want   int
+	}{
+		{"valid", Filter{Track: []string{"trump"}, Follow: []string{"42"}}, 0},
+		{"empty", Filter{}, 1},
+		{"too many terms", Filter{Track: make([]string, maxTrackTerms+1)}, 1},
+		{"term too long", Filter{Track: []string{strings.Repeat("a", maxTrackLength+1)}}, 1},
+		{"non-numeric user ID", Filter{Follow: []string{"dwhitena"}}, 1},
+		{"box out of range", Filter{Locations: []BoundingBox{{-200, 0, 10, 10}}}, 1},
+		{"box corners swapped", Filter{Locations: []BoundingBox{{10, 10, 0, 0}}}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.filter.Validate(); len(got) != tt.want {
+			t.Errorf("%s: Validate() = %q, want %d problems", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    []BoundingBox
+		wantErr bool
+	}{
+		{"-74.3,40.5,-73.7,40.9", []BoundingBox{{-74.3, 40.5, -73.7, 40.9}}, false},
+		{"-74.3,40.5,-73.7", nil, true},
+		{"-74.3,40.5,-73.7,north", nil, true},
+	}
+	for _, tt := range tests {
+		got, err := ParseLocations(tt.s)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseLocations(%q) = %v, %v, want %v", tt.s, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// recordWriter writes scored tweets to a file in some format.
+type recordWriter interface {
+	Write(t ScoredTweet) error
+
+	// Flush writes any buffered tweets to the file, if the format can do
+	// that without hurting the file.
+	Flush() error
+
+	// Buffered returns the size in bytes of the tweets held in memory and
+	// not yet written to the file.
+	Buffered() int64
+
+	// Close flushes the tweets and finishes the file, e.g., by writing a
+	// footer. It doesn't close the file itself.
+	Close() error
+}
+
+// fileFormats are the formats a FileSink can write, by file extension.
+var fileFormats = map[string]func(w io.Writer) (recordWriter, error){
+	"jsonl":   newJSONLWriter,
+	"csv":     newCSVWriter,
+	"parquet": newParquetWriter,
+}
+
+// jsonlWriter writes each scored tweet as a line of JSON, with all of its
+// fields.
+type jsonlWriter struct {
+	buf *bufio.Writer
+	enc *json.Encoder
+}
+
+func newJSONLWriter(w io.Writer) (recordWriter, error) {
+	buf := bufio.NewWriter(w)
+	return &jsonlWriter{buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+func (j *jsonlWriter) Write(t ScoredTweet) error { return j.enc.Encode(t) }
+func (j *jsonlWriter) Flush() error              { return j.buf.Flush() }
+func (j *jsonlWriter) Buffered() int64           { return int64(j.buf.Buffered()) }
+func (j *jsonlWriter) Close() error              { return j.buf.Flush() }
+
+// tweetRecord is a scored tweet flattened into a row, for the CSV and
+// Parquet formats.
+type tweetRecord struct {
+	ID            string   `parquet:"name=id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	CreatedAt     int64    `parquet:"name=created_at, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	UserID        string   `parquet:"name=user_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ScreenName    string   `parquet:"name=screen_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Lang          string   `parquet:"name=lang, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Text          string   `parquet:"name=text, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Terms         []string `parquet:"name=terms, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	TextSentiment float64  `parquet:"name=text_sentiment, type=DOUBLE"`
+	Sentiment     float64  `parquet:"name=sentiment, type=DOUBLE"`
+	Label         string   `parquet:"name=label, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Account       string   `parquet:"name=account, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Weight        float64  `parquet:"name=weight, type=DOUBLE"`
+	Influence     float64  `parquet:"name=influence, type=DOUBLE"`
+	Analyzer      string   `parquet:"name=analyzer, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// newTweetRecord flattens a scored tweet. Tweets without a time get the
+// current time.
+func newTweetRecord(t ScoredTweet) tweetRecord {
+	created, ok := t.Time()
+	if !ok {
+		created = time.Now()
+	}
+	return tweetRecord{
+		ID:            t.ID,
+		CreatedAt:     created.UnixMilli(),
+		UserID:        t.User.ID,
+		ScreenName:    t.User.ScreenName,
+		Lang:          t.Lang,
+		Text:          t.Text,
+		Terms:         t.Terms,
+		TextSentiment: t.TextSentiment,
+		Sentiment:     t.Sentiment,
+		Label:         t.Label,
+		Account:       t.Account,
+		Weight:        t.Weight,
+		Influence:     t.Influence,
+		Analyzer:      t.Analyzer,
+	}
+}
+
+// csvHeader names the CSV columns.
+var csvHeader = []string{
+	"id", "created_at", "user_id", "screen_name", "lang", "text", "terms",
+	"text_sentiment", "sentiment", "label", "account", "weight", "influence", "analyzer",
+}
+
+// csvWriter writes scored tweets as CSV with a header row. Times are in
+// RFC 3339 and terms are separated by semicolons.
+type csvWriter struct {
+	buf *bufio.Writer
+	w   *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) (recordWriter, error) {
+
+	// The csv.Writer buffers into buf itself, rather than adding a buffer
+	// of its own, so we can tell how much is buffered.
+	buf := bufio.NewWriter(w)
+	c := &csvWriter{buf: buf, w: csv.NewWriter(buf)}
+	if err := c.w.Write(csvHeader); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *csvWriter) Write(t ScoredTweet) error {
+	r := newTweetRecord(t)
+	return c.w.Write([]string{
+		r.ID,
+		time.UnixMilli(r.CreatedAt).UTC().Format(time.RFC3339),
+		r.UserID,
+		r.ScreenName,
+		r.Lang,
+		r.Text,
+		strings.Join(r.Terms, ";"),
+		formatFloat(r.TextSentiment),
+		formatFloat(r.Sentiment),
+		r.Label,
+		r.Account,
+		formatFloat(r.Weight),
+		formatFloat(r.Influence),
+		r.Analyzer,
+	})
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) Buffered() int64 { return int64(c.buf.Buffered()) }
+func (c *csvWriter) Close() error    { return c.Flush() }
+
+// formatFloat formats a float as briefly as possible.
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// parquetRowGroupSize bounds the tweets held in memory before a Parquet
+// row group is written.
+const parquetRowGroupSize = 16 * 1024 * 1024
+
+// parquetWriter writes scored tweets as Parquet. The rows are buffered
+// into row groups, which are written when they reach parquetRowGroupSize
+// or the file is finished. The file is only readable once it is finished,
+// so Flush does nothing rather than write lots of tiny row groups, which
+// would make the file slow to read and compress badly.
+type parquetWriter struct {
+	pw *writer.ParquetWriter
+}
+
+func newParquetWriter(w io.Writer) (recordWriter, error) {
+	pw, err := writer.NewParquetWriterFromWriter(w, new(tweetRecord), 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	return &parquetWriter{pw: pw}, nil
+}
+
+func (p *parquetWriter) Write(t ScoredTweet) error { return p.pw.Write(newTweetRecord(t)) }
+func (p *parquetWriter) Flush() error              { return nil }
+func (p *parquetWriter) Close() error              { return p.pw.WriteStop() }
+
+// Buffered counts both the encoded pages of the current row group and the
+// rows not yet encoded.
+func (p *parquetWriter) Buffered() int64 { return p.pw.Size + p.pw.ObjsSize }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// influenceSchemes weight tweets by the influence of their author, by the
+// name used in the config. Each returns a factor of at least 1, and the
+// factors of the configured schemes are multiplied together.
+var influenceSchemes = map[string]func(Tweet) float64{
+
+	// An account with 10 million followers counts about 8 times as much
+	// as one with none.
+	"followers": func(t Tweet) float64 {
+		return 1 + math.Log10(1+float64(t.User.FollowersCount))
+	},
+
+	// Retweets and likes, when Twitter sends them.
+	"engagement": func(t Tweet) float64 {
+		return 1 + math.Log10(1+float64(t.RetweetCount+t.FavoriteCount))
+	},
+
+	"verified": func(t Tweet) float64 {
+		if t.User.Verified {
+			return 2
+		}
+		return 1
+	},
+}
+
+// Influence returns the weight of a tweet under the named schemes. It is 1
+// if there are none.
+func Influence(t Tweet, schemes []string) float64 {
+	influence := 1.0
+	for _, name := range schemes {
+		if scheme, ok := influenceSchemes[name]; ok {
+			influence *= scheme(t)
+		}
+	}
+	return influence
+}
+
+// validateInfluence checks the names of the influence schemes.
+func validateInfluence(schemes []string) error {
+	for _, name := range schemes {
+		if _, ok := influenceSchemes[name]; !ok {
+			return fmt.Errorf("unknown influence scheme %q (known schemes: %s)", name, strings.Join(influenceSchemeNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// influenceSchemeNames returns the names of the influence schemes.
+func influenceSchemeNames() []string {
+	var names []string
+	for name := range influenceSchemes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestInfluence(t *testing.T) {
+	tests := []struct {
+		name    string
+		tweet   Tweet
+		schemes []string
+		want    float64
+	}{
+		{"no schemes", Tweet{User: User{FollowersCount: 999}}, nil, 1},
+		{"no followers", Tweet{}, []string{"followers"}, 1},
+		{"9 followers", Tweet{User: User{FollowersCount: 9}}, []string{"followers"}, 2},
+		{"999 followers", Tweet{User: User{FollowersCount: 999}}, []string{"followers"}, 4},
+		{"engagement", Tweet{RetweetCount: 90, FavoriteCount: 9}, []string{"engagement"}, 3},
+		{"verified", Tweet{User: User{Verified: true}}, []string{"verified"}, 2},
+		{"not verified", Tweet{}, []string{"verified"}, 1},
+		{
+			name:    "schemes multiply",
+			tweet:   Tweet{User: User{FollowersCount: 999, Verified: true}, RetweetCount: 9},
+			schemes: []string{"followers", "engagement", "verified"},
+			want:    4 * 2 * 2,
+		},
+		{"unknown scheme", Tweet{User: User{FollowersCount: 9}}, []string{"followers", "karma"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Influence(tt.tweet, tt.schemes); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakdownWeighting(t *testing.T) {
+	type tweet struct {
+		sentiment, weight, influence float64
+	}
+	tests := []struct {
+		name         string
+		tweets       []tweet
+		wantAverage  float64
+		wantWeighted float64
+	}{
+		{"equal influence", []tweet{{1, 1, 1}, {0, 1, 1}}, 0.5, 0.5},
+		{"influential author", []tweet{{1, 1, 3}, {0, 1, 1}}, 0.5, 0.75},
+		{"down-weighted author", []tweet{{1, 0.25, 1}, {0, 1, 1}}, 0.2, 0.2},
+		{"down-weighted influential author", []tweet{{1, 0.25, 4}, {0, 1, 1}}, 0.2, 0.5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewBreakdown()
+			for _, tw := range tt.tweets {
+				b.Add(tw.sentiment, tw.weight, tw.influence)
+			}
+			if math.Abs(b.SentimentAverage-tt.wantAverage) > 1e-9 {
+				t.Errorf("got average %v, want %v", b.SentimentAverage, tt.wantAverage)
+			}
+			if math.Abs(b.WeightedAverage-tt.wantWeighted) > 1e-9 {
+				t.Errorf("got weighted average %v, want %v", b.WeightedAverage, tt.wantWeighted)
+			}
+		})
+	}
+}
+
+func TestValidateInfluence(t *testing.T) {
+	if err := validateInfluence([]string{"followers", "engagement", "verified"}); err != nil {
+		t.Errorf("got %v for the known schemes", err)
+	}
+	if err := validateInfluence([]string{"karma"}); err == nil {
+		t.Error("got no error for an unknown scheme")
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// undetermined is the language code Twitter uses when it can't tell.
+const undetermined = "und"
+
+// languageSamples are short passages of everyday text that the trigram
+// profiles for the Latin-script languages are built from.
+var languageSamples = map[string]string{
+	"en": `All human beings are born free and equal in dignity and rights. They are endowed with reason and conscience and should act towards one another in a spirit of brotherhood.
+I think this is the best thing that has happened today, but what do you think about it? We were going to the game with our friends and it was really great.
+Please let me know if you have any questions. I love this so much, thank you for everything you have done for us. This is not what they said would happen and people are angry.
+The president said that the government will make a decision about the new law next week. Why would anyone want to do that? It is just the way things are going right now.`,
+
+	"es": `Todos los seres humanos nacen libres e iguales en dignidad y derechos y, dotados como están de razón y conciencia, deben comportarse fraternalmente los unos con los otros.
+Creo que esto es lo mejor que ha pasado hoy, pero ¿qué piensas tú? Íbamos al partido con nuestros amigos y fue muy bueno.
+Por favor avísame si tienes alguna pregunta. Me encanta mucho, gracias por todo lo que has hecho por nosotros. Esto no es lo que dijeron que pasaría y la gente está enojada.
+El presidente dijo que el gobierno tomará una decisión sobre la nueva ley la próxima semana. ¿Por qué alguien querría hacer eso? Así son las cosas ahora mismo.`,
+
+	"fr": `Tous les êtres humains naissent libres et égaux en dignité et en droits. Ils sont doués de raison et de conscience et doivent agir les uns envers les autres dans un esprit de fraternité.
+Je pense que c'est la meilleure chose qui soit arrivée aujourd'hui, mais qu'est-ce que tu en penses? Nous allions au match avec nos amis et c'était vraiment super.
+S'il vous plaît, dites-moi si vous avez des questions. J'adore ça, merci pour tout ce que vous avez fait pour nous. Ce n'est pas ce qu'ils avaient dit et les gens sont en colère.
+Le président a dit que le gouvernement prendra une décision sur la nouvelle loi la semaine prochaine. Pourquoi quelqu'un voudrait faire ça? C'est comme ça en ce moment.`,
+
+	"de": `Alle Menschen sind frei und gleich an Würde und Rechten geboren. Sie sind mit Vernunft und Gewissen begabt und sollen einander im Geist der Brüderlichkeit begegnen.
+Ich glaube, das ist das Beste, was heute passiert ist, aber was denkst du darüber? Wir sind mit unseren Freunden zum Spiel gegangen und es war wirklich toll.
+Bitte sag mir Bescheid, wenn du Fragen hast. Ich liebe das so sehr, danke für alles, was ihr für uns getan habt. Das ist nicht das, was sie gesagt haben, und die Leute sind wütend.
+Der Präsident sagte, dass die Regierung nächste Woche eine Entscheidung über das neue Gesetz treffen wird. Warum sollte jemand das tun wollen? So ist es eben gerade.`,
+
+	"it": `Tutti gli esseri umani nascono liberi ed eguali in dignità e diritti. Essi sono dotati di ragione e di coscienza e devono agire gli uni verso gli altri in spirito di fratellanza.
+Penso che questa sia la cosa migliore che sia successa oggi, ma tu cosa ne pensi? Stavamo andando alla partita con i nostri amici ed è stato davvero bello.
+Per favore fammi sapere se hai domande. Mi piace tantissimo, grazie per tutto quello che avete fatto per noi. Non è quello che avevano detto e la gente è arrabbiata.
+Il presidente ha detto che il governo prenderà una decisione sulla nuova legge la prossima settimana. Perché qualcuno dovrebbe volerlo fare? Le cose vanno così adesso.`,
+
+	"pt": `Todos os seres humanos nascem livres e iguais em dignidade e em direitos. Dotados de razão e de consciência, devem agir uns para com os outros em espírito de fraternidade.
+Eu acho que isso é a melhor coisa que aconteceu hoje, mas o que você acha? Nós estávamos indo para o jogo com os nossos amigos e foi muito bom.
+Por favor me avise se você tiver alguma pergunta. Eu amo muito isso, obrigado por tudo o que vocês fizeram por nós. Não é isso que eles disseram e as pessoas estão com raiva.
+O presidente disse que o governo vai tomar uma decisão sobre a nova lei na próxima semana. Por que alguém iria querer fazer isso? É assim que as coisas estão agora.`,
+
+	"nl": `Alle mensen worden vrij en gelijk in waardigheid en rechten geboren. Zij zijn begiftigd met verstand en geweten, en behoren zich jegens elkander in een geest van broederschap te gedragen.
+Ik denk dat dit het beste is wat er vandaag is gebeurd, maar wat vind jij ervan? We gingen met onze vrienden naar de wedstrijd en het was echt geweldig.
+Laat het me alsjeblieft weten als je vragen hebt. Ik vind dit zo leuk, bedankt voor alles wat jullie voor ons hebben gedaan. Dit is niet wat ze zeiden en de mensen zijn boos.
+De president zei dat de regering volgende week een beslissing over de nieuwe wet zal nemen. Waarom zou iemand dat willen doen? Zo gaat het nu eenmaal op dit moment.`,
+}
+
+// scriptLanguages maps scripts that are (mostly) used by a single language
+// to that language.
+var scriptLanguages = []struct {
+	script *unicode.RangeTable
+	lang   string
+}{
+	{unicode.Hiragana, "ja"},
+	{unicode.Katakana, "ja"},
+	{unicode.Hangul, "ko"},
+	{unicode.Arabic, "ar"},
+	{unicode.Cyrillic, "ru"},
+	{unicode.Greek, "el"},
+	{unicode.Hebrew, "he"},
+	{unicode.Thai, "th"},
+	{unicode.Devanagari, "hi"},
+	{unicode.Han, "zh"},
+}
+
+// trigramProfile is the trigram frequencies of a language.
+type trigramProfile struct {
+	counts map[string]int
+	total  int
+}
+
+// languageProfiles are built from languageSamples on start up.
+var languageProfiles = buildProfiles(languageSamples)
+
+// buildProfiles builds a trigram profile for each sample.
+func buildProfiles(samples map[string]string) map[string]*trigramProfile {
+	profiles := make(map[string]*trigramProfile, len(samples))
+	for lang, sample := range samples {
+		p := &trigramProfile{counts: make(map[string]int)}
+		for _, tri := range trigrams(sample) {
+			p.counts[tri]++
+			p.total++
+		}
+		profiles[lang] = p
+	}
+	return profiles
+}
+
+// trigrams returns the letter trigrams of the words in the text, with each
+// word padded by a space on either side. URLs, mentions and hashtags are
+// left out, as they don't say much about the language.
+func trigrams(text string) []string {
+	var tris []string
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if strings.HasPrefix(word, "http") || strings.HasPrefix(word, "@") || strings.HasPrefix(word, "#") {
+			continue
+		}
+		word = strings.TrimFunc(word, func(r rune) bool { return !unicode.IsLetter(r) })
+		if word == "" {
+			continue
+		}
+		runes := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(runes); i++ {
+			tris = append(tris, string(runes[i:i+3]))
+		}
+	}
+	return tris
+}
+
+// DetectLanguage guesses the language of the text, returning a BCP 47
+// code like Twitter's lang field, or "und" if it can't tell.
+func DetectLanguage(text string) string {
+
+	// Scripts used by a single language give it away.
+	letters := 0
+	scripts := make(map[string]int)
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		for _, sl := range scriptLanguages {
+			if unicode.Is(sl.script, r) {
+				scripts[sl.lang]++
+				break
+			}
+		}
+	}
+	if letters == 0 {
+		return undetermined
+	}
+
+	// Japanese mixes kana with Han characters.
+	if scripts["ja"] > 0 {
+		return "ja"
+	}
+	best, bestCount := "", 0
+	for lang, n := range scripts {
+		if n > bestCount {
+			best, bestCount = lang, n
+		}
+	}
+	if bestCount*2 > letters {
+		return best
+	}
+
+	// Otherwise score the trigrams against each profile (naive Bayes with
+	// add-one smoothing).
+	tris := trigrams(text)
+	if len(tris) < 8 {
+		return undetermined
+	}
+	bestScore := math.Inf(-1)
+	secondScore := math.Inf(-1)
+	for lang, p := range languageProfiles {
+		score := 0.0
+		for _, tri := range tris {
+			score += math.Log(float64(p.counts[tri]+1) / float64(p.total+len(p.counts)))
+		}
+		switch {
+		case score > bestScore:
+			best, bestScore, secondScore = lang, score, bestScore
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+
+	// Don't guess if it is too close to call.
+	if (bestScore-secondScore)/float64(len(tris)) < 0.05 {
+		return undetermined
+	}
+	return best
+}
+
+// Language returns the language of the tweet, from Twitter's lang field if
+// it has one and from DetectLanguage otherwise.
+func (t Tweet) Language() string {
+	if t.Lang != "" && t.Lang != undetermined {
+		return t.Lang
+	}
+	return DetectLanguage(t.Text)
+}
@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestDetectLanguage(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"I think this is the best thing that happened to us today, thank you so much", "en"},
+		{"Creo que esto es lo mejor que nos ha pasado hoy, muchas gracias por todo", "es"},
+		{"Je pense que c'est la meilleure chose qui nous soit arrivée aujourd'hui", "fr"},
+		{"Ich glaube, das ist das Beste, was uns heute passiert ist, vielen Dank", "de"},
+		{"Привет, как дела? Всё хорошо", "ru"},
+		{"今日はとても良い天気ですね", "ja"},
+		{"오늘 날씨가 정말 좋네요", "ko"},
+		{"", "und"},
+		{"1234 !!! :)", "und"},
+		{"lol ok", "und"},
+	}
+	for _, tt := range tests {
+		if got := DetectLanguage(tt.text); got != tt.want {
+			t.Errorf("DetectLanguage(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTweetLanguage(t *testing.T) {
+	tests := []struct {
+		lang string
+		text string
+		want string
+	}{
+		{"fr", "I think this is the best thing that happened to us today", "fr"},
+		{"", "I think this is the best thing that happened to us today", "en"},
+		{"und", "I think this is the best thing that happened to us today", "en"},
+	}
+	for _, tt := range tests {
+		tweet := Tweet{Lang: tt.lang, Text: tt.text}
+		if got := tweet.Language(); got != tt.want {
+			t.Errorf("Tweet{Lang: %q}.Language() = %q, want %q", tt.lang, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+// Command sentiment streams tweets from Twitter, analyzes their sentiment
+// and keeps aggregate stats about them.
+//
+// Usage:
+//
+//	sentiment stream [flags]     analyze tweets from the streaming API or NATS
+//	sentiment reprocess [flags]  replay tweets that failed analysis
+//	sentiment terms [flags] ...  list, add or remove the tracked terms
+//	sentiment query [flags]      aggregate the tweets saved to SQLite
+//
+// Run a command with -h to see its flags. Every flag can also be set in a
+// YAML config file (-config) or with a SENTIMENT_* environment variable.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+)
+
+const usage = `Usage:
+
+  sentiment stream [flags]     analyze tweets from the streaming API or NATS
+  sentiment reprocess [flags]  replay tweets that failed analysis
+  sentiment terms [flags] ...  list, add or remove the tracked terms
+                               of a running stream
+  sentiment query [flags]      aggregate the tweets saved to SQLite
+
+Run a command with -h to see its flags.
+`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	var err error
+	switch cmd, args := os.Args[1], os.Args[2:]; cmd {
+	case "stream":
+		var cfg Config
+		if cfg, err = LoadConfig(cmd, args); err == nil {
+			err = runStream(cfg)
+		}
+	case "reprocess":
+		var cfg Config
+		if cfg, err = LoadConfig(cmd, args); err == nil {
+			err = reprocess(cfg)
+		}
+	case "terms":
+		var cfg Config
+		if cfg, err = LoadConfig(cmd, args); err == nil {
+			err = runTerms(cfg, commandArgs(cmd, args))
+		}
+	case "query":
+		var cfg Config
+		if cfg, err = LoadConfig(cmd, args); err == nil {
+			err = runQuery(cfg)
+		}
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", cmd, usage)
+		os.Exit(2)
+	}
+
+	switch {
+	case err == flag.ErrHelp:
+	case err != nil:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The pipeline's metrics, served on /metrics.
+var (
+	tweetsReceived = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_tweets_received_total",
+		Help: "Tweets received from the stream.",
+	})
+	controlMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentiment_control_messages_total",
+		Help: "Control messages received from the stream, by kind.",
+	}, []string{"kind"})
+	tweetsScored = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_tweets_scored_total",
+		Help: "Tweets analyzed and scored.",
+	})
+	tweetsSkipped = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentiment_tweets_skipped_total",
+		Help: "Tweets not analyzed, by reason.",
+	}, []string{"reason"})
+	analysisErrors = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_analysis_errors_total",
+		Help: "Tweets that failed analysis and were dead-lettered.",
+	})
+	workersBusy = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sentiment_workers_busy",
+		Help: "Workers currently processing a tweet.",
+	})
+	analyzerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sentiment_analyzer_duration_seconds",
+		Help:    "Time taken by analyzer calls, including retries, by language and outcome.",
+		Buckets: prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"lang", "outcome"})
+	natsPublished = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_nats_published_total",
+		Help: "Messages published to NATS and acknowledged.",
+	})
+	natsPublishFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_nats_publish_failures_total",
+		Help: "Attempts to publish a message to NATS that failed and were retried.",
+	})
+	natsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_nats_dropped_total",
+		Help: "Messages dropped because the NATS buffer was full.",
+	})
+	natsBuffered = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sentiment_nats_buffered",
+		Help: "Messages waiting to be published to NATS.",
+	})
+	sqliteFailures = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_sqlite_failures_total",
+		Help: "Attempts to write a batch of tweets to SQLite that failed and were retried.",
+	})
+	sqliteDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentiment_sqlite_dropped_total",
+		Help: "Tweets dropped because they couldn't be written to SQLite.",
+	})
+)
+
+// NewMetricsRegistry creates a registry with the pipeline's metrics, the
+// stats and the Go runtime metrics. The lengths of the queues are read
+// when the metrics are scraped.
+func NewMetricsRegistry(stats *ShardedStats, queues map[string]func() int) *prometheus.Registry {
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(
+		tweetsReceived,
+		controlMessages,
+		tweetsScored,
+		tweetsSkipped,
+		analysisErrors,
+		workersBusy,
+		analyzerDuration,
+		natsPublished,
+		natsPublishFailures,
+		natsDropped,
+		natsBuffered,
+		sqliteFailures,
+		sqliteDropped,
+		&statsCollector{stats: stats},
+		prometheus.NewGoCollector(),
+		prometheus.NewProcessCollector(prometheus.ProcessCollectorOpts{}),
+	)
+	for name, length := range queues {
+		length := length
+		reg.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name:        "sentiment_queue_depth",
+			Help:        "Tweets waiting in a queue.",
+			ConstLabels: prometheus.Labels{"queue": name},
+		}, func() float64 { return float64(length()) }))
+	}
+	return reg
+}
+
+// statsCollector exports the stats when the metrics are scraped.
+type statsCollector struct {
+	stats *ShardedStats
+}
+
+var (
+	labelTweetsDesc = prometheus.NewDesc(
+		"sentiment_tweets_by_label_total",
+		"Tweets scored, by label.",
+		[]string{"label"}, nil,
+	)
+	sentimentDesc = prometheus.NewDesc(
+		"sentiment_average",
+		"Average sentiment of all tweets, from 0 (negative) to 1 (positive).",
+		nil, nil,
+	)
+	termSentimentDesc = prometheus.NewDesc(
+		"sentiment_term_average",
+		"Average sentiment towards each tracked term.",
+		[]string{"term"}, nil,
+	)
+	termTweetsDesc = prometheus.NewDesc(
+		"sentiment_term_tweets_total",
+		"Tweets mentioning each tracked term, by label.",
+		[]string{"term", "label"}, nil,
+	)
+)
+
+// Describe implements prometheus.Collector.
+func (c *statsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- labelTweetsDesc
+	ch <- sentimentDesc
+	ch <- termSentimentDesc
+	ch <- termTweetsDesc
+}
+
+// Collect implements prometheus.Collector.
+func (c *statsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats.Snapshot()
+	for _, label := range []string{"positive", "negative", "neutral"} {
+		ch <- prometheus.MustNewConstMetric(labelTweetsDesc, prometheus.CounterValue, float64(s.Counts[label]), label)
+	}
+	ch <- prometheus.MustNewConstMetric(sentimentDesc, prometheus.GaugeValue, s.SentimentAverage)
+	for term, b := range s.Terms {
+		ch <- prometheus.MustNewConstMetric(termSentimentDesc, prometheus.GaugeValue, b.SentimentAverage, term)
+		for _, label := range []string{"positive", "negative", "neutral"} {
+			ch <- prometheus.MustNewConstMetric(termTweetsDesc, prometheus.CounterValue, float64(b.Counts[label]), term, label)
+		}
+	}
+}
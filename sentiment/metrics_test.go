@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMetricsRegistry(t *testing.T) {
+	stats := NewShardedStats(NewStats(), 1)
+	var st ScoredTweet
+	st.Sentiment = 0.9
+	st.Weight = 1
+	st.Influence = 1
+	st.Aspects = []Aspect{{Name: "Trump", Kind: aspectTerm, Sentiment: 0.9}}
+	stats.Shard(0).Record(st)
+	reg := NewMetricsRegistry(stats, map[string]func() int{"tweets": func() int { return 7 }})
+
+	names := []string{
+		"sentiment_tweets_received_total",
+		"sentiment_tweets_scored_total",
+		"sentiment_analysis_errors_total",
+		"sentiment_workers_busy",
+		"sentiment_nats_published_total",
+		"sentiment_nats_buffered",
+		"sentiment_queue_depth",
+		"sentiment_tweets_by_label_total",
+		"sentiment_average",
+		"sentiment_term_average",
+		"sentiment_term_tweets_total",
+	}
+	n, err := testutil.GatherAndCount(reg, names...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The labelled stats have a series for each label, and for each label
+	// of each term.
+	if want := 7 + 3 + 1 + 1 + 3; n != want {
+		t.Errorf("got %d series, want %d", n, want)
+	}
+
+	expected := `
+# HELP sentiment_queue_depth Tweets waiting in a queue.
+# TYPE sentiment_queue_depth gauge
+sentiment_queue_depth{queue="tweets"} 7
+# HELP sentiment_term_average Average sentiment towards each tracked term.
+# TYPE sentiment_term_average gauge
+sentiment_term_average{term="Trump"} 0.9
+`
+	if err := testutil.GatherAndCompare(reg, strings.NewReader(expected), "sentiment_queue_depth", "sentiment_term_average"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestPipelineMetrics(t *testing.T) {
+	unavailable := errors.New("503 Service Unavailable")
+	analyzer := NewResilientAnalyzer(&fakeAnalyzer{errs: []error{unavailable}}, nil, Policy{FailureThreshold: 10, OpenTimeout: time.Minute})
+	deadLetters, err := OpenDeadLetterSink(t.TempDir() + "/deadletter.jsonl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer deadLetters.Close()
+	p := &Pipeline{
+		Router:      &LanguageRouter{Default: analyzer, Allowed: map[string]bool{"en": true}},
+		DeadLetters: deadLetters,
+	}
+
+	scored := testutil.ToFloat64(tweetsScored)
+	errored := testutil.ToFloat64(analysisErrors)
+	skipped := testutil.ToFloat64(tweetsSkipped.WithLabelValues(skipLanguage))
+
+	// The first tweet fails, the second is scored and the third is in a
+	// language we don't analyze.
+	ctx, cancel := context.WithCancel(context.Background())
+	tweets := make(chan Tweet)
+	done := make(chan struct{})
+	go func() {
+		p.tweetWorker(ctx, NewStats(), tweets)
+		close(done)
+	}()
+	tweets <- Tweet{Text: "I love this", Lang: "en"}
+	tweets <- Tweet{Text: "I love this", Lang: "en"}
+	tweets <- Tweet{Text: "J'adore ça", Lang: "fr"}
+	cancel()
+	<-done
+
+	if got := testutil.ToFloat64(tweetsScored) - scored; got != 1 {
+		t.Errorf("got %v more tweets scored, want 1", got)
+	}
+	if got := testutil.ToFloat64(analysisErrors) - errored; got != 1 {
+		t.Errorf("got %v more analysis errors, want 1", got)
+	}
+	if got := testutil.ToFloat64(tweetsSkipped.WithLabelValues(skipLanguage)) - skipped; got != 1 {
+		t.Errorf("got %v more tweets skipped for their language, want 1", got)
+	}
+	if got := testutil.ToFloat64(workersBusy); got != 0 {
+		t.Errorf("got %v busy workers, want 0", got)
+	}
+	if got := testutil.CollectAndCount(analyzerDuration); got < 2 {
+		t.Errorf("got %d analyzer duration series, want one each for ok and error", got)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// NormalizeFunc is a single step of text normalization.
+type NormalizeFunc func(string) string
+
+// Normalizer cleans up tweet text before it is scored by applying its
+// steps in order.
+type Normalizer []NormalizeFunc
+
+// Normalize applies the steps to the text.
+func (n Normalizer) Normalize(text string) string {
+	for _, step := range n {
+		text = step(text)
+	}
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// normalizeSteps are the normalization steps, by the name used in the
+// config.
+var normalizeSteps = map[string]NormalizeFunc{
+	"html":                html.UnescapeString,
+	"retweet":             stripRetweet,
+	"urls":                replaceURLs(""),
+	"url-placeholder":     replaceURLs("URL"),
+	"mentions":            replaceMentions(""),
+	"mention-placeholder": replaceMentions("@user"),
+	"hashtags":            segmentHashtags,
+	"elongated":           squashElongated,
+	"emoji":               emojiToText,
+}
+
+// defaultNormalize are the steps applied unless configured otherwise.
+var defaultNormalize = []string{"html", "retweet", "urls", "mentions", "hashtags", "elongated", "emoji"}
+
+// NewNormalizer creates a Normalizer from the named steps.
+func NewNormalizer(steps []string) (Normalizer, error) {
+	var n Normalizer
+	for _, name := range steps {
+		step, ok := normalizeSteps[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown normalization step %q (known steps: %s)", name, strings.Join(normalizeStepNames(), ", "))
+		}
+		n = append(n, step)
+	}
+	return n, nil
+}
+
+// mentionSteps are the normalization steps that remove @mentions.
+var mentionSteps = map[string]bool{"mentions": true, "mention-placeholder": true}
+
+// keepMentions returns the steps without those that remove @mentions.
+func keepMentions(steps []string) []string {
+	var kept []string
+	for _, name := range steps {
+		if !mentionSteps[name] {
+			kept = append(kept, name)
+		}
+	}
+	return kept
+}
+
+// normalizeStepNames returns the names of the normalization steps.
+func normalizeStepNames() []string {
+	var names []string
+	for name := range normalizeSteps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	retweetRe = regexp.MustCompile(`^RT @\w+:\s*`)
+	urlRe     = regexp.MustCompile(`https?://\S+`)
+	mentionRe = regexp.MustCompile(`@\w+`)
+	hashtagRe = regexp.MustCompile(`#(\w+)`)
+	camelRe   = regexp.MustCompile(`([\p{Ll}\d])(\p{Lu})|(\p{Lu})(\p{Lu}\p{Ll})|(\p{L})(\d)`)
+)
+
+// stripRetweet removes the "RT @user:" prefix of old-style retweets.
+func stripRetweet(text string) string {
+	return retweetRe.ReplaceAllString(text, "")
+}
+
+// replaceURLs returns a step replacing URLs with the placeholder.
+func replaceURLs(placeholder string) NormalizeFunc {
+	return func(text string) string {
+		return urlRe.ReplaceAllString(text, placeholder)
+	}
+}
+
+// replaceMentions returns a step replacing @mentions with the placeholder.
+func replaceMentions(placeholder string) NormalizeFunc {
+	return func(text string) string {
+		return mentionRe.ReplaceAllString(text, placeholder)
+	}
+}
+
+// segmentHashtags turns camel-case hashtags into words, so that
+// "#MakeAmericaGreatAgain" becomes "Make America Great Again".
+func segmentHashtags(text string) string {
+	return hashtagRe.ReplaceAllStringFunc(text, func(tag string) string {
+		words := strings.TrimPrefix(tag, "#")
+		words = strings.ReplaceAll(words, "_", " ")
+
+		// Run twice, as the matches can't overlap.
+		for i := 0; i < 2; i++ {
+			words = camelRe.ReplaceAllString(words, "$1$3$5 $2$4$6")
+		}
+		return words
+	})
+}
+
+// squashElongated shortens letters repeated for emphasis to two, so that
+// "sooooo goooood" becomes "soo good".
+func squashElongated(text string) string {
+
+	// Go's regexp has no backreferences, so do it by hand.
+	var b strings.Builder
+	var last rune
+	run := 0
+	for _, r := range text {
+		if r == last && unicode.IsLetter(r) {
+			run++
+		} else {
+			last, run = r, 1
+		}
+		if run <= 2 {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// emojiToText replaces emoji with words describing them, so the analyzer
+// can pick up on the sentiment they carry.
+func emojiToText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		if isEmojiModifier(r) {
+			continue
+		}
+		if name, ok := emojiNames[r]; ok {
+			b.WriteString(" " + name + " ")
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// isEmojiModifier reports whether r only modifies the emoji before it (a
+// variation selector, skin tone or zero width joiner).
+func isEmojiModifier(r rune) bool {
+	return r == 0xFE0F || r == 0x200D || (r >= 0x1F3FB && r <= 0x1F3FF)
+}
+
+// emojiNames are words for common emoji.
+var emojiNames = map[rune]string{
+	'😀': "grinning",
+	'😁': "beaming",
+	'😂': "laughing",
+	'🤣': "laughing",
+	'😃': "smiling",
+	'😄': "smiling",
+	'😅': "relieved",
+	'😆': "laughing",
+	'😉': "winking",
+	'😊': "happy",
+	'😍': "love",
+	'😘': "kiss",
+	'🥰': "love",
+	'🙂': "smiling",
+	'🤔': "thinking",
+	'😐': "neutral",
+	'😑': "expressionless",
+	'🙄': "eye roll",
+	'😏': "smirking",
+	'😒': "unamused",
+	'😔': "sad",
+	'😕': "confused",
+	'🙁': "frowning",
+	'😞': "disappointed",
+	'😟': "worried",
+	'😢': "crying",
+	'😭': "sobbing",
+	'😤': "frustrated",
+	'😠': "angry",
+	'😡': "furious",
+	'🤬': "cursing",
+	'😱': "screaming",
+	'😨': "fearful",
+	'😳': "shocked",
+	'🤮': "disgusted",
+	'🤢': "nauseated",
+	'💩': "crap",
+	'🤡': "clown",
+	'👍': "thumbs up",
+	'👎': "thumbs down",
+	'👏': "applause",
+	'🙏': "thanks",
+	'💪': "strong",
+	'🔥': "fire",
+	'💯': "hundred percent",
+	'🎉': "celebration",
+	'❤': "love",
+	'💔': "broken heart",
+	'✅': "yes",
+	'❌': "no",
+	'⚠': "warning",
+	'🚨': "alarm",
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestNormalizeSteps(t *testing.T) {
+	tests := []struct {
+		step string
+		text string
+		want string
+	}{
+		{"html", "Tom &amp; Jerry &lt;3", "Tom & Jerry <3"},
+		{"retweet", "RT @someone: great news", "great news"},
+		{"retweet", "not RT @someone: a retweet", "not RT @someone: a retweet"},
+		{"urls", "look https://t.co/abc here", "look here"},
+		{"url-placeholder", "look https://t.co/abc here", "look URL here"},
+		{"mentions", "thanks @someone!", "thanks !"},
+		{"mention-placeholder", "thanks @someone!", "thanks @user!"},
+		{"hashtags", "#MakeAmericaGreatAgain", "Make America Great Again"},
+		{"hashtags", "#NASA2020 #climate_change", "NASA 2020 climate change"},
+		{"elongated", "sooooo goooood!!!", "soo good!!!"},
+		{"emoji", "great 😂", "great laughing"},
+		{"emoji", "ok 👍🏽", "ok thumbs up"},
+	}
+	for _, tt := range tests {
+		n, err := NewNormalizer([]string{tt.step})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := n.Normalize(tt.text); got != tt.want {
+			t.Errorf("%s: Normalize(%q) = %q, want %q", tt.step, tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	n, err := NewNormalizer(defaultNormalize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	text := "RT @news: Sooooo happy &amp; #FeelingGood https://t.co/x 😂"
+	want := "Soo happy & Feeling Good laughing"
+	if got := n.Normalize(text); got != want {
+		t.Errorf("Normalize(%q) = %q, want %q", text, got, want)
+	}
+}
+
+func TestNewNormalizerUnknownStep(t *testing.T) {
+	if _, err := NewNormalizer([]string{"html", "stemming"}); err == nil {
+		t.Error("got no error for an unknown step")
+	}
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// statsFile is the JSON form of Stats, used by the API and, along with the
+// windowed stats, on disk.
+type statsFile struct {
+	Breakdown
+	Languages map[string]*Breakdown     `json:"languages,omitempty"`
+	Terms     map[string]*Breakdown     `json:"terms,omitempty"`
+	Accounts  map[string]*Breakdown     `json:"accounts,omitempty"`
+	Dropped   map[string]int            `json:"dropped,omitempty"`
+	Users     []*UserProfile            `json:"users,omitempty"`
+	Emoji     map[string]map[string]int `json:"emoji,omitempty"`
+}
+
+// checkpointFile is what is saved between runs: the stats, plus the
+// windowed stats so the recent windows survive a restart too.
+type checkpointFile struct {
+	statsFile
+	Windows []windowBucketFile `json:"windows,omitempty"`
+	SavedAt time.Time          `json:"saved_at"`
+}
+
+// windowBucketFile is the JSON form of a bucket of the windowed stats.
+type windowBucketFile struct {
+	Start time.Time             `json:"start"`
+	Total *Breakdown            `json:"total"`
+	Terms map[string]*Breakdown `json:"terms,omitempty"`
+}
+
+// LoadStats loads the stats saved at path, restoring the windowed stats
+// saved with them into windows if it isn't nil. If there is no file yet it
+// returns new, empty stats.
+func LoadStats(path string, windows *WindowedStats) (*Stats, error) {
+	s := NewStats()
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var cf checkpointFile
+	if err := json.Unmarshal(data, &cf); err != nil {
+		return nil, err
+	}
+	sf := cf.statsFile
+	s.Breakdown.Merge(&sf.Breakdown)
+	for lang, b := range sf.Languages {
+		breakdownFor(s.Languages, lang).Merge(b)
+	}
+	for term, b := range sf.Terms {
+		breakdownFor(s.Terms, term).Merge(b)
+	}
+	for kind, b := range sf.Accounts {
+		breakdownFor(s.Accounts, kind).Merge(b)
+	}
+	mergeCounts(s.Dropped, sf.Dropped)
+	s.Users.set(sf.Users)
+	for term, counts := range sf.Emoji {
+		mergeCounts(countsFor(s.Emoji, term), counts)
+	}
+	if windows != nil {
+		windows.restore(cf.Windows)
+	}
+	return s, nil
+}
+
+// Save writes the stats, and the windowed stats if windows isn't nil, to
+// path. The file is replaced atomically so a crash never leaves
+// half-written stats behind.
+func (s *Stats) Save(path string, windows *WindowedStats) error {
+	cf := checkpointFile{
+		statsFile: s.file(),
+		SavedAt:   time.Now().UTC(),
+	}
+	if windows != nil {
+		cf.Windows = windows.file()
+	}
+	data, err := json.MarshalIndent(cf, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// file returns a copy of the stats in their JSON form.
+func (s *Stats) file() statsFile {
+	copied := NewStats()
+	copied.Merge(s)
+	return statsFile{
+		Breakdown: copied.Breakdown,
+		Languages: copied.Languages,
+		Terms:     copied.Terms,
+		Accounts:  copied.Accounts,
+		Dropped:   copied.Dropped,
+		Users:     copied.Users.profilePointers(),
+		Emoji:     copied.Emoji,
+	}
+}
+
+// writeFileAtomic writes data to a temporary file next to path and renames
+// it into place.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	// Sync the directory too, so the rename itself survives a crash.
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
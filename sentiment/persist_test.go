@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSaveLoadStats(t *testing.T) {
+	now := time.Date(2018, 3, 7, 12, 30, 0, 0, time.UTC)
+	s := NewStats()
+	windows := NewWindowedStats(windowBucket, windowBuckets)
+	for i, sentiment := range []float64{0.9, 0.1, 0.5} {
+		var st ScoredTweet
+		st.ID = "1"
+		st.Lang = "en"
+		st.User.ID = "42"
+		st.User.ScreenName = "someone"
+		st.Sentiment = sentiment
+		st.Weight = 1
+		st.Influence = 2
+		st.Account = "human"
+		st.Terms = []string{"Trump"}
+		st.Emoji.Emoji = []string{"😀"}
+		st.Aspects = []Aspect{{Name: "Trump", Kind: aspectTerm, Sentiment: sentiment}}
+		s.Record(st)
+		windows.Record(st, now.Add(time.Duration(i)*windowBucket))
+	}
+	s.RecordDropped(skipLanguage)
+
+	path := filepath.Join(t.TempDir(), "stats.json")
+	if err := s.Save(path, windows); err != nil {
+		t.Fatal(err)
+	}
+	restored := NewWindowedStats(windowBucket, windowBuckets)
+	loaded, err := LoadStats(path, restored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := loaded.file(), s.file(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got stats %+v, want %+v", got, want)
+	}
+	if got, want := restored.file(), windows.file(); !reflect.DeepEqual(got, want) {
+		t.Errorf("got windows %+v, want %+v", got, want)
+	}
+	if got := restored.Window(time.Hour, now.Add(2*windowBucket)).Counts["total"]; got != 3 {
+		t.Errorf("got %d tweets in the restored window, want 3", got)
+	}
+
+	// Loading without windows only restores the stats.
+	if _, err := LoadStats(path, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestLoadStatsMissing(t *testing.T) {
+	windows := NewWindowedStats(windowBucket, windowBuckets)
+	s, err := LoadStats(filepath.Join(t.TempDir(), "stats.json"), windows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Counts["total"]; got != 0 {
+		t.Errorf("got %d tweets, want new stats", got)
+	}
+	if got := windows.file(); len(got) != 0 {
+		t.Errorf("got windows %+v, want none", got)
+	}
+}
+
+func TestLoadStatsCorrupt(t *testing.T) {
+	for name, data := range map[string]string{
+		"truncated":  `{"sentiment_average": 0.5, "counts": {"tot`,
+		"not json":   "stats",
+		"wrong type": `{"counts": "many"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "stats.json")
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// The stream won't start rather than overwrite the corrupt
+			// checkpoint with new stats.
+			if _, err := LoadStats(path, NewWindowedStats(windowBucket, windowBuckets)); err == nil {
+				t.Error("loaded corrupt stats")
+			}
+		})
+	}
+}
+
+func TestSaveStatsAtomic(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "stats.json")
+	s := NewStats()
+	for i := 0; i < 2; i++ {
+		if err := s.Save(path, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("got %d files, want only the stats and no temporary files", len(files))
+	}
+}
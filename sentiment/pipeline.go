@@ -0,0 +1,257 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reasons for skipping tweets.
+const (
+	skipLanguage = "language"
+	skipEmpty    = "empty"
+	skipBot      = "bot"
+)
+
+// SkipError is returned by Score for tweets we don't analyze, e.g.,
+// because of their language or because nothing is left of their text after
+// normalization.
+type SkipError struct {
+	Reason string
+}
+
+func (e *SkipError) Error() string {
+	return "tweet skipped: " + e.Reason
+}
+
+// Pipeline holds what the workers need to turn tweets into scored tweets.
+type Pipeline struct {
+
+	// Router picks the analyzer for each tweet.
+	Router *LanguageRouter
+
+	// Normalizer cleans up the text before it is analyzed.
+	Normalizer Normalizer
+
+	// EmojiWeight is how much the emoji signal counts towards the
+	// sentiment, from 0 to 1.
+	EmojiWeight float64
+
+	// Aspects analyzes the clauses of tweets that mention several terms or
+	// entities separately, to get the sentiment towards each of them. The
+	// clauses are split from the text cleaned up by ClauseNormalizer, which
+	// keeps the @mentions that Normalizer may remove, so aspects like
+	// "@Trump" are still found.
+	Aspects          bool
+	ClauseNormalizer Normalizer
+
+	// Bots, if set, scores tweets for how likely they are to come from a
+	// bot. Those scoring at least BotThreshold are handled as BotAction
+	// says: flagged, down-weighted to BotWeight, or dropped.
+	Bots         *BotDetector
+	BotThreshold float64
+	BotAction    string
+	BotWeight    float64
+
+	// Influence are the schemes weighting tweets by the influence of their
+	// author in the weighted averages.
+	Influence []string
+
+	// Parked holds tweets waiting for their analyzer's breaker to close.
+	Parked chan Tweet
+
+	// DeadLetters receives the tweets that fail analysis.
+	DeadLetters *DeadLetterSink
+
+	// Results, if set, receives the scored tweets. With Ordered, it also
+	// receives a release marker for each tweet that was skipped, parked or
+	// dead-lettered, so Reorder doesn't wait for it.
+	Results chan<- ScoredTweet
+	Ordered bool
+}
+
+// NewPipeline creates the configured analyzers and normalizer. The caller
+// sets up the channels and dead-letter sink, if it needs them.
+func NewPipeline(cfg Config) (*Pipeline, error) {
+	router, err := NewRouter(cfg)
+	if err != nil {
+		return nil, err
+	}
+	normalizer, err := NewNormalizer(cfg.Normalize)
+	if err != nil {
+		return nil, err
+	}
+	clauseNormalizer, err := NewNormalizer(keepMentions(cfg.Normalize))
+	if err != nil {
+		return nil, err
+	}
+	p := &Pipeline{
+		Router:           router,
+		Normalizer:       normalizer,
+		EmojiWeight:      cfg.EmojiWeight,
+		Aspects:          cfg.Aspects,
+		ClauseNormalizer: clauseNormalizer,
+		BotThreshold:     cfg.BotThreshold,
+		BotAction:        cfg.BotAction,
+		BotWeight:        cfg.BotWeight,
+		Influence:        cfg.Influence,
+	}
+	if cfg.BotAction != botActionOff {
+		p.Bots = NewBotDetector()
+	}
+	return p, nil
+}
+
+// Score analyzes a single tweet. The tweet's text is normalized before it
+// is analyzed, but the scored tweet keeps the original text. The emoji are
+// scored from the original text too, as normalization may replace them.
+// On error, the scored tweet holds just the tweet, with its language and
+// bot score filled in so they aren't worked out again if it is retried.
+func (p *Pipeline) Score(ctx context.Context, t Tweet) (ScoredTweet, error) {
+
+	// Score tweets for bots as they arrive, before we spend an analyzer
+	// call on them.
+	weight, account := 1.0, ""
+	if p.Bots != nil {
+		if t.BotScore == nil {
+			score := p.Bots.Score(t, time.Now())
+			t.BotScore = &score
+		}
+		account = accountHuman
+		if *t.BotScore >= p.BotThreshold {
+			account = accountBot
+			switch p.BotAction {
+			case botActionDrop:
+				return ScoredTweet{Tweet: t}, &SkipError{skipBot}
+			case botActionDownweight:
+				weight = p.BotWeight
+			}
+		}
+	}
+
+	// Pick the analyzer for the tweet's language.
+	t.Lang = t.Language()
+	analyzer, ok := p.Router.For(t.Lang)
+	if !ok {
+		return ScoredTweet{Tweet: t}, &SkipError{skipLanguage}
+	}
+
+	// Clean up the text.
+	text := p.Normalizer.Normalize(t.Text)
+	if text == "" {
+		return ScoredTweet{Tweet: t}, &SkipError{skipEmpty}
+	}
+
+	// Analyze the tweet.
+	start := time.Now()
+	analysis, err := analyzer.Analyze(ctx, text)
+	outcome := "ok"
+	if err != nil {
+		outcome = "error"
+	}
+	analyzerDuration.WithLabelValues(t.Lang, outcome).Observe(time.Since(start).Seconds())
+	if err != nil {
+		return ScoredTweet{Tweet: t}, err
+	}
+
+	// Get the sentiment, blending in the emoji.
+	textSentiment := Sentiment(analysis)
+	emoji := ScoreEmoji(t.Text)
+	sentiment := blendSentiment(textSentiment, emoji, p.EmojiWeight)
+
+	// Get the sentiment towards each term and entity.
+	aspects := p.scoreAspects(ctx, analyzer, t.Text, t.Terms, analysis, sentiment)
+
+	return ScoredTweet{
+		Tweet:         t,
+		AnalyzedText:  text,
+		TextSentiment: textSentiment,
+		Emoji:         emoji,
+		Aspects:       aspects,
+		Sentiment:     sentiment,
+		Label:         Label(sentiment),
+		Account:       account,
+		Weight:        weight,
+		Influence:     Influence(t, p.Influence),
+		Analyzer:      analyzer.Name,
+		Analysis:      analysis,
+	}, nil
+}
+
+// tweetWorker processes tweets off a buffered channel, scoring each one and
+// counting those Score skips. Tweets that can't be analyzed because the
+// circuit breaker is open are parked for later, and tweets that fail
+// analysis go to the dead-letter sink. Scored tweets are sent on the
+// results channel, if there is one, in the order they finish. Each tweet is
+// acknowledged to its source once it is scored, skipped or dead-lettered.
+func (p *Pipeline) tweetWorker(ctx context.Context, myStats StatsRecorder, tweets chan Tweet) {
+	for {
+		select {
+
+		// Stop the goroutine.
+		case <-ctx.Done():
+			return
+
+		// Print the tweets.
+		case t := <-tweets:
+
+			// Score the tweet.
+			workersBusy.Inc()
+			scored, err := p.Score(ctx, t)
+			workersBusy.Dec()
+			var skip *SkipError
+			if errors.As(err, &skip) {
+				tweetsSkipped.WithLabelValues(skip.Reason).Inc()
+				myStats.RecordDropped(skip.Reason)
+				t.Done()
+				p.release(t)
+				continue
+			}
+			t = scored.Tweet
+			if errors.Is(err, ErrCircuitOpen) {
+				select {
+				case p.Parked <- t:
+					p.release(t)
+					continue
+				default:
+					err = fmt.Errorf("parked tweet queue is full: %w", err)
+				}
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				analysisErrors.Inc()
+				fmt.Println("Analysis error:", err)
+				if err := p.DeadLetters.Write(t, err); err != nil {
+					fmt.Println("Error writing dead letter:", err)
+				} else {
+					t.Done()
+				}
+				p.release(t)
+				continue
+			}
+
+			// Update the stats.
+			tweetsScored.Inc()
+			myStats.Record(scored)
+			t.Done()
+
+			// Pass on the result. Whatever reads the results keeps on
+			// until they are closed, so this doesn't lose scored tweets
+			// when we are stopping.
+			if p.Results != nil {
+				p.Results <- scored
+			}
+		}
+	}
+}
+
+// release sends a release marker for a tweet that won't be scored, if the
+// results are being reordered.
+func (p *Pipeline) release(t Tweet) {
+	if p.Ordered && p.Results != nil {
+		p.Results <- releaseMarker(t.Seq)
+	}
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+// ErrCircuitOpen is returned when the circuit breaker is rejecting calls
+// and there is no fallback analyzer.
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// AttemptError is returned when an analysis still fails after retrying.
+type AttemptError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *AttemptError) Error() string {
+	return fmt.Sprintf("%v (after %d attempts)", e.Err, e.Attempts)
+}
+
+// Unwrap returns the error from the last attempt.
+func (e *AttemptError) Unwrap() error {
+	return e.Err
+}
+
+// Policy controls the timeouts, retries and circuit breaking applied to
+// calls to an Analyzer.
+type Policy struct {
+
+	// Timeout is the deadline for a single call.
+	Timeout time.Duration
+
+	// MaxRetries is the number of times a transient failure is retried.
+	MaxRetries int
+
+	// BaseBackoff and MaxBackoff bound the jittered exponential backoff
+	// between retries.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+
+	// FailureThreshold is the number of consecutive failed calls that
+	// opens the circuit breaker.
+	FailureThreshold int
+
+	// OpenTimeout is how long the breaker stays open before it lets a
+	// probe call through.
+	OpenTimeout time.Duration
+}
+
+// DefaultPolicy is a reasonable policy for a local MachineBox.
+var DefaultPolicy = Policy{
+	Timeout:          2 * time.Second,
+	MaxRetries:       2,
+	BaseBackoff:      100 * time.Millisecond,
+	MaxBackoff:       2 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      5 * time.Second,
+}
+
+// backoff returns the jittered delay before the given retry attempt.
+func (p Policy) backoff(attempt int) time.Duration {
+	d := p.BaseBackoff << uint(attempt)
+	if d <= 0 || d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	// Use "full jitter" so retrying workers don't hit MachineBox in lockstep.
+	return time.Duration(rand.Int63n(int64(d)))
+}
+
+// Breaker states.
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker stops calls to a failing dependency after a number of
+// consecutive failures and lets a single probe through once it has been
+// open for a while.
+type CircuitBreaker struct {
+	threshold   int
+	openTimeout time.Duration
+	state       int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	mux         sync.Mutex
+}
+
+// NewCircuitBreaker creates a new, closed CircuitBreaker.
+func NewCircuitBreaker(threshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		threshold:   threshold,
+		openTimeout: openTimeout,
+	}
+}
+
+// Allow reports whether a call may go through.
+func (b *CircuitBreaker) Allow() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.openTimeout {
+			return false
+		}
+		b.state = breakerHalfOpen
+		b.probing = true
+		return true
+	case breakerHalfOpen:
+
+		// Only one probe at a time.
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	}
+	return true
+}
+
+// Ready reports whether the breaker would let a call through right now,
+// without reserving a probe.
+func (b *CircuitBreaker) Ready() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		return time.Since(b.openedAt) >= b.openTimeout
+	case breakerHalfOpen:
+		return !b.probing
+	}
+	return true
+}
+
+// Closed reports whether the breaker is closed.
+func (b *CircuitBreaker) Closed() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	return b.state == breakerClosed
+}
+
+// Success records a successful call and closes the breaker.
+func (b *CircuitBreaker) Success() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.state = breakerClosed
+	b.failures = 0
+	b.probing = false
+}
+
+// Cancel records a call that was abandoned by the caller, freeing the
+// probe slot without changing the state.
+func (b *CircuitBreaker) Cancel() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.probing = false
+}
+
+// Failure records a failed call, opening the breaker if there have been
+// too many in a row or if a probe failed.
+func (b *CircuitBreaker) Failure() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.failures++
+	b.probing = false
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// ResilientAnalyzer wraps an Analyzer with per-call timeouts, retries and
+// a circuit breaker. While the breaker is open calls go to the Fallback
+// analyzer, or fail with ErrCircuitOpen if there is none.
+type ResilientAnalyzer struct {
+
+	// Name identifies the backend in the scored tweets, like
+	// machinebox@http://localhost:8080.
+	Name string
+
+	Analyzer Analyzer
+	Fallback Analyzer
+	Policy   Policy
+	Breaker  *CircuitBreaker
+}
+
+// NewResilientAnalyzer creates a new ResilientAnalyzer. The fallback may
+// be nil.
+func NewResilientAnalyzer(a, fallback Analyzer, p Policy) *ResilientAnalyzer {
+	return &ResilientAnalyzer{
+		Analyzer: a,
+		Fallback: fallback,
+		Policy:   p,
+		Breaker:  NewCircuitBreaker(p.FailureThreshold, p.OpenTimeout),
+	}
+}
+
+// Analyze analyzes the text according to the policy.
+func (r *ResilientAnalyzer) Analyze(ctx context.Context, text string) (*textbox.Analysis, error) {
+	var err error
+	for attempt := 0; attempt <= r.Policy.MaxRetries; attempt++ {
+
+		// Wait before retrying.
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(r.Policy.backoff(attempt - 1)):
+			}
+		}
+
+		// Divert the call if the breaker is open.
+		if !r.Breaker.Allow() {
+			if r.Fallback != nil {
+				return r.Fallback.Analyze(ctx, text)
+			}
+			return nil, ErrCircuitOpen
+		}
+
+		// Make the call with its own deadline.
+		var analysis *textbox.Analysis
+		analysis, err = r.call(ctx, text)
+		if err == nil {
+			r.Breaker.Success()
+			return analysis, nil
+		}
+
+		// Shutting down isn't MachineBox's fault.
+		if ctx.Err() != nil {
+			r.Breaker.Cancel()
+			return nil, ctx.Err()
+		}
+		r.Breaker.Failure()
+
+		if !isTransient(err) {
+			return nil, &AttemptError{Attempts: attempt + 1, Err: err}
+		}
+	}
+	return nil, &AttemptError{Attempts: r.Policy.MaxRetries + 1, Err: err}
+}
+
+// call makes a single call to the wrapped analyzer.
+func (r *ResilientAnalyzer) call(ctx context.Context, text string) (*textbox.Analysis, error) {
+	if r.Policy.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Policy.Timeout)
+		defer cancel()
+	}
+	return r.Analyzer.Analyze(ctx, text)
+}
+
+// isTransient reports whether an error is worth retrying.
+func isTransient(err error) bool {
+	var netErr net.Error
+	switch {
+	case errors.Is(err, context.DeadlineExceeded),
+		errors.Is(err, io.EOF),
+		errors.Is(err, io.ErrUnexpectedEOF),
+		errors.As(err, &netErr):
+		return true
+	}
+
+	// The textbox client reports bad HTTP statuses as plain errors.
+	switch code, _ := statusCode(err); code {
+	case http.StatusTooManyRequests, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// statusCode returns the HTTP status code of an error the textbox client
+// made from a bad response, whose message is the response's status line,
+// like "503 Service Unavailable".
+func statusCode(err error) (int, bool) {
+	code, _, _ := strings.Cut(err.Error(), " ")
+	if len(code) != 3 {
+		return 0, false
+	}
+	n, convErr := strconv.Atoi(code)
+	if convErr != nil || n < 100 || n > 599 {
+		return 0, false
+	}
+	return n, true
+}
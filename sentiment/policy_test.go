@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	const openTimeout = 20 * time.Millisecond
+
+	// Each step is a call to the breaker. For "allow" the step also has the
+	// result Allow should return; "wait" waits out the open timeout.
+	type step struct {
+		op   string
+		want bool
+	}
+	failures := []step{{"failure", false}, {"failure", false}, {"failure", false}}
+	tests := []struct {
+		name       string
+		steps      []step
+		wantClosed bool
+	}{
+		{
+			name:       "closed below the threshold",
+			steps:      []step{{"failure", false}, {"failure", false}, {"allow", true}},
+			wantClosed: true,
+		},
+		{
+			name:       "opens at the threshold",
+			steps:      append(failures, step{"allow", false}),
+			wantClosed: false,
+		},
+		{
+			name: "success resets the failures",
+			steps: []step{
+				{"failure", false}, {"failure", false}, {"success", false},
+				{"failure", false}, {"failure", false}, {"allow", true},
+			},
+			wantClosed: true,
+		},
+		{
+			name:       "one probe after the open timeout",
+			steps:      append(failures, step{"wait", false}, step{"allow", true}, step{"allow", false}),
+			wantClosed: false,
+		},
+		{
+			name:       "probe success closes",
+			steps:      append(failures, step{"wait", false}, step{"allow", true}, step{"success", false}, step{"allow", true}),
+			wantClosed: true,
+		},
+		{
+			name:       "probe failure reopens",
+			steps:      append(failures, step{"wait", false}, step{"allow", true}, step{"failure", false}, step{"allow", false}),
+			wantClosed: false,
+		},
+		{
+			name:       "cancel frees the probe",
+			steps:      append(failures, step{"wait", false}, step{"allow", true}, step{"cancel", false}, step{"allow", true}),
+			wantClosed: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := NewCircuitBreaker(3, openTimeout)
+			for i, s := range tt.steps {
+				switch s.op {
+				case "allow":
+					if got := b.Allow(); got != s.want {
+						t.Fatalf("step %d: Allow() = %v, want %v", i, got, s.want)
+					}
+				case "success":
+					b.Success()
+				case "failure":
+					b.Failure()
+				case "cancel":
+					b.Cancel()
+				case "wait":
+					time.Sleep(openTimeout + 10*time.Millisecond)
+				}
+			}
+			if got := b.Closed(); got != tt.wantClosed {
+				t.Errorf("Closed() = %v, want %v", got, tt.wantClosed)
+			}
+		})
+	}
+}
+
+// fakeAnalyzer returns each of errs in turn, then succeeds.
+type fakeAnalyzer struct {
+	errs  []error
+	calls int
+}
+
+func (f *fakeAnalyzer) Analyze(ctx context.Context, text string) (*textbox.Analysis, error) {
+	f.calls++
+	if f.calls <= len(f.errs) {
+		return nil, f.errs[f.calls-1]
+	}
+	return &textbox.Analysis{}, nil
+}
+
+func TestResilientAnalyzer(t *testing.T) {
+	unavailable := errors.New("503 Service Unavailable")
+	badRequest := errors.New("400 Bad Request")
+	tests := []struct {
+		name         string
+		errs         []error
+		threshold    int
+		fallback     bool
+		wantCalls    int
+		wantFallback int
+		wantErr      error
+		wantAttempts int
+	}{
+		{
+			name:      "success",
+			threshold: 10,
+			wantCalls: 1,
+		},
+		{
+			name:      "retries transient errors",
+			errs:      []error{unavailable, io.EOF},
+			threshold: 10,
+			wantCalls: 3,
+		},
+		{
+			name:         "gives up after the retries",
+			errs:         []error{unavailable, unavailable, unavailable},
+			threshold:    10,
+			wantCalls:    3,
+			wantErr:      unavailable,
+			wantAttempts: 3,
+		},
+		{
+			name:         "doesn't retry other statuses",
+			errs:         []error{badRequest},
+			threshold:    10,
+			wantCalls:    1,
+			wantErr:      badRequest,
+			wantAttempts: 1,
+		},
+		{
+			name:         "doesn't retry status digits elsewhere",
+			errs:         []error{errors.New("tweet 503 failed")},
+			threshold:    10,
+			wantCalls:    1,
+			wantAttempts: 1,
+		},
+		{
+			name:      "open breaker without a fallback",
+			errs:      []error{unavailable},
+			threshold: 1,
+			wantCalls: 1,
+			wantErr:   ErrCircuitOpen,
+		},
+		{
+			name:         "open breaker with a fallback",
+			errs:         []error{unavailable},
+			threshold:    1,
+			fallback:     true,
+			wantCalls:    1,
+			wantFallback: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &fakeAnalyzer{errs: tt.errs}
+			fallback := &fakeAnalyzer{}
+			p := Policy{MaxRetries: 2, FailureThreshold: tt.threshold, OpenTimeout: time.Minute}
+			r := NewResilientAnalyzer(a, nil, p)
+			if tt.fallback {
+				r.Fallback = fallback
+			}
+
+			_, err := r.Analyze(context.Background(), "text")
+			if a.calls != tt.wantCalls {
+				t.Errorf("got %d calls, want %d", a.calls, tt.wantCalls)
+			}
+			if fallback.calls != tt.wantFallback {
+				t.Errorf("got %d fallback calls, want %d", fallback.calls, tt.wantFallback)
+			}
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("got error %v, want %v", err, tt.wantErr)
+			}
+			var attemptErr *AttemptError
+			switch {
+			case tt.wantAttempts > 0 && !errors.As(err, &attemptErr):
+				t.Errorf("got error %v, want an AttemptError", err)
+			case tt.wantAttempts > 0 && attemptErr.Attempts != tt.wantAttempts:
+				t.Errorf("got %d attempts, want %d", attemptErr.Attempts, tt.wantAttempts)
+			case tt.wantAttempts == 0 && tt.wantErr == nil && err != nil:
+				t.Errorf("got error %v, want none", err)
+			}
+		})
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	tests := []struct {
+		msg      string
+		wantCode int
+		wantOK   bool
+	}{
+		{"503 Service Unavailable", 503, true},
+		{"429 Too Many Requests", 429, true},
+		{"200", 200, true},
+		{"tweet 503 failed", 0, false},
+		{"5030 errors", 0, false},
+		{"999 Unknown", 0, false},
+		{"", 0, false},
+	}
+	for _, tt := range tests {
+		code, ok := statusCode(errors.New(tt.msg))
+		if code != tt.wantCode || ok != tt.wantOK {
+			t.Errorf("statusCode(%q) = %d, %v, want %d, %v", tt.msg, code, ok, tt.wantCode, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// QueryOptions are the options of the query command.
+type QueryOptions struct {
+
+	// From and To bound the time range, as RFC 3339 times, dates like
+	// 2006-01-02 or durations before now like 168h.
+	From, To string
+
+	// Term limits the query to one term.
+	Term string
+
+	// By groups the tweets by "hour" or "day", and by "term".
+	By []string
+}
+
+// runQuery prints aggregates of the scored tweets in the SQLite database:
+// the number of tweets with each label and their average sentiment, over
+// a time range, optionally grouped by hour or day and by term.
+func runQuery(cfg Config) error {
+	if cfg.SQLitePath == "" {
+		return errors.New("-sqlite is required")
+	}
+	if _, err := os.Stat(cfg.SQLitePath); err != nil {
+		return fmt.Errorf("opening database: %v", err)
+	}
+
+	// Work out the time range.
+	q := cfg.Query
+	now := time.Now()
+	from, err := parseQueryTime(q.From, now.Add(-24*time.Hour), now, false)
+	if err != nil {
+		return fmt.Errorf("-from: %v", err)
+	}
+	to, err := parseQueryTime(q.To, now, now, true)
+	if err != nil {
+		return fmt.Errorf("-to: %v", err)
+	}
+
+	db, err := openSQLite(cfg.SQLitePath)
+	if err != nil {
+		return fmt.Errorf("opening database: %v", err)
+	}
+	defer db.Close()
+	return printAggregates(os.Stdout, db, q, from, to)
+}
+
+// printAggregates queries the database for the aggregates of the tweets
+// from from up to, but not including, to, and prints them to out as a
+// table.
+func printAggregates(out io.Writer, db *sql.DB, q QueryOptions, from, to time.Time) error {
+
+	// Work out the grouping.
+	var bucket string
+	perTerm := q.Term != ""
+	for _, by := range q.By {
+		switch by {
+		case "hour", "day":
+			if bucket != "" {
+				return errors.New("-by can't group by both hour and day")
+			}
+			bucket = by
+		case "term":
+			perTerm = true
+		default:
+			return fmt.Errorf("-by %q is not one of hour, day or term", by)
+		}
+	}
+
+	// Build the query. Per-term aggregates use the sentiment towards each
+	// term, the others that of the whole tweet.
+	table := "tweets"
+	if perTerm {
+		table = "tweet_terms"
+	}
+	var columns, groups, where []string
+	args := []interface{}{from.Unix(), to.Unix()}
+	switch bucket {
+	case "hour":
+		columns = append(columns, "strftime('%Y-%m-%d %H:00', created_at, 'unixepoch')")
+	case "day":
+		columns = append(columns, "strftime('%Y-%m-%d', created_at, 'unixepoch')")
+	}
+	if perTerm {
+		columns = append(columns, "term")
+	}
+	for i := range columns {
+		groups = append(groups, fmt.Sprint(i+1))
+	}
+	where = append(where, "created_at >= ?", "created_at < ?")
+	if q.Term != "" {
+		where = append(where, "term = ? COLLATE NOCASE")
+		args = append(args, q.Term)
+	}
+	// Without grouping there is a row even if there are no tweets, and
+	// its sums are NULL.
+	query := "SELECT " + strings.Join(append(columns,
+		"COUNT(*)",
+		"COALESCE(SUM(label = 'positive'), 0)",
+		"COALESCE(SUM(label = 'negative'), 0)",
+		"COALESCE(SUM(label = 'neutral'), 0)",
+		"AVG(sentiment)",
+	), ", ") + " FROM " + table + " WHERE " + strings.Join(where, " AND ")
+	if len(groups) > 0 {
+		query += " GROUP BY " + strings.Join(groups, ", ") + " ORDER BY " + strings.Join(groups, ", ")
+	}
+
+	// Run it.
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return fmt.Errorf("querying database: %v", err)
+	}
+	defer rows.Close()
+
+	// Print the results.
+	fmt.Fprintf(out, "Tweets from %s to %s (UTC)\n\n", from.UTC().Format(time.RFC3339), to.UTC().Format(time.RFC3339))
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	var header []string
+	if bucket != "" {
+		header = append(header, strings.ToUpper(bucket))
+	}
+	if perTerm {
+		header = append(header, "TERM")
+	}
+	header = append(header, "TWEETS", "POSITIVE", "NEGATIVE", "NEUTRAL", "SENTIMENT")
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for rows.Next() {
+		var group [2]string
+		var total, positive, negative, neutral int
+		var sentiment *float64
+		dest := []interface{}{}
+		for i := range columns {
+			dest = append(dest, &group[i])
+		}
+		dest = append(dest, &total, &positive, &negative, &neutral, &sentiment)
+		if err := rows.Scan(dest...); err != nil {
+			return fmt.Errorf("reading results: %v", err)
+		}
+		if total == 0 {
+			continue
+		}
+		row := append([]string{}, group[:len(columns)]...)
+		row = append(row, fmt.Sprint(total), fmt.Sprint(positive), fmt.Sprint(negative), fmt.Sprint(neutral), fmt.Sprintf("%0.2f", *sentiment))
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("reading results: %v", err)
+	}
+	return w.Flush()
+}
+
+// parseQueryTime parses a time given as an RFC 3339 time, a date or a
+// duration before now. It returns def if s is empty. A date is the
+// midnight it starts at, or if end is true the midnight it ends at, so
+// that a range to a date includes the whole day.
+func parseQueryTime(s string, def, now time.Time, end bool) (time.Time, error) {
+	if s == "" {
+		return def, nil
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse("2006-01-02", s); err == nil {
+		if end {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is not a time like 2006-01-02T15:04:05Z, a date like 2006-01-02 or a duration like 168h", s)
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestPrintAggregates(t *testing.T) {
+	db := openTestDB(t, []ScoredTweet{
+		queryTweet("1", "2017-03-01T10:15:00Z", 0.9, Aspect{Name: "trump", Sentiment: 0.9}, Aspect{Name: "russia", Sentiment: 0.2}),
+		queryTweet("2", "2017-03-01T10:45:00Z", 0.1, Aspect{Name: "trump", Sentiment: 0.1}),
+		queryTweet("3", "2017-03-01T11:30:00Z", 0.6, Aspect{Name: "russia", Sentiment: 0.6}),
+		queryTweet("4", "2017-03-02T09:00:00Z", 0.95),
+	})
+
+	tests := []struct {
+		name    string
+		q       QueryOptions
+		from    string
+		to      string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "totals",
+			want: []string{
+				"TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"4 2 1 1 0.64",
+			},
+		},
+		{
+			name: "by day",
+			q:    QueryOptions{By: []string{"day"}},
+			want: []string{
+				"DAY TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"2017-03-01 3 1 1 1 0.53",
+				"2017-03-02 1 1 0 0 0.95",
+			},
+		},
+		{
+			name: "by hour",
+			q:    QueryOptions{By: []string{"hour"}},
+			want: []string{
+				"HOUR TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"2017-03-01 10:00 2 1 1 0 0.50",
+				"2017-03-01 11:00 1 0 0 1 0.60",
+				"2017-03-02 09:00 1 1 0 0 0.95",
+			},
+		},
+		{
+			name: "by term",
+			q:    QueryOptions{By: []string{"term"}},
+			want: []string{
+				"TERM TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"russia 2 0 1 1 0.40",
+				"trump 2 1 1 0 0.50",
+			},
+		},
+		{
+			name: "one term, ignoring case",
+			q:    QueryOptions{Term: "Trump", By: []string{"day"}},
+			want: []string{
+				"DAY TERM TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"2017-03-01 trump 2 1 1 0 0.50",
+			},
+		},
+		{
+			name: "time range",
+			from: "2017-03-02T00:00:00Z",
+			want: []string{
+				"TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"1 1 0 0 0.95",
+			},
+		},
+		{
+			name: "to a date",
+			to:   "2017-03-01",
+			want: []string{
+				"TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"3 1 1 1 0.53",
+			},
+		},
+		{
+			name: "to a time",
+			to:   "2017-03-01T11:30:00Z",
+			want: []string{
+				"TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+				"2 1 1 0 0.50",
+			},
+		},
+		{
+			name: "empty time range",
+			from: "2017-03-02T12:00:00Z",
+			want: []string{
+				"TWEETS POSITIVE NEGATIVE NEUTRAL SENTIMENT",
+			},
+		},
+		{
+			name:    "hour and day",
+			q:       QueryOptions{By: []string{"hour", "day"}},
+			wantErr: true,
+		},
+		{
+			name:    "unknown grouping",
+			q:       QueryOptions{By: []string{"week"}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from := mustParseTime(t, "2017-03-01T00:00:00Z")
+			if tt.from != "" {
+				from = mustParseTime(t, tt.from)
+			}
+			to := mustParseTime(t, "2017-03-03T00:00:00Z")
+			if tt.to != "" {
+				var err error
+				if to, err = parseQueryTime(tt.to, to, time.Now(), true); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			var out bytes.Buffer
+			err := printAggregates(&out, db, tt.q, from, to)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("got no error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := tableLines(out.String()); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSQLiteSinkLocalIDs(t *testing.T) {
+	// Tweets without IDs, e.g., from the reprocess command, are all kept.
+	var tweets []ScoredTweet
+	for i := 0; i < 3; i++ {
+		tweets = append(tweets, queryTweet("", "2017-03-01T10:00:00Z", 0.9))
+	}
+	db := openTestDB(t, tweets)
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tweets").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != len(tweets) {
+		t.Errorf("got %d tweets, want %d", n, len(tweets))
+	}
+}
+
+func TestSQLiteSinkRetries(t *testing.T) {
+	s, db := openFailingSink(t)
+	failures := testutil.ToFloat64(sqliteFailures)
+
+	// A full batch is written straight away, and fails.
+	for i := 0; i < sqliteBatchSize; i++ {
+		s.Write(queryTweet(fmt.Sprint(i), "2017-03-01T10:00:00Z", 0.9))
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(sqliteFailures) == failures {
+		if time.Now().After(deadline) {
+			t.Fatal("the batch didn't fail")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Once the database is fixed the batch is written after all.
+	if _, err := db.Exec("DROP TRIGGER fail"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM tweets").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	if n != sqliteBatchSize {
+		t.Errorf("got %d tweets, want %d", n, sqliteBatchSize)
+	}
+}
+
+func TestSQLiteSinkDrops(t *testing.T) {
+	s, _ := openFailingSink(t)
+	dropped := testutil.ToFloat64(sqliteDropped)
+
+	// Tweets that still can't be written when the sink is closed are
+	// dropped and counted.
+	s.Write(queryTweet("1", "2017-03-01T10:00:00Z", 0.9))
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := testutil.ToFloat64(sqliteDropped) - dropped; got != 1 {
+		t.Errorf("got %v more tweets dropped, want 1", got)
+	}
+}
+
+// openFailingSink opens a SQLiteSink whose inserts fail until the fail
+// trigger is dropped, along with another connection to its database.
+func openFailingSink(t *testing.T) (*SQLiteSink, *sql.DB) {
+	path := filepath.Join(t.TempDir(), "tweets.db")
+	s, err := OpenSQLiteSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if _, err := db.Exec(`CREATE TRIGGER fail BEFORE INSERT ON tweets
+		BEGIN SELECT RAISE(FAIL, 'disk full'); END`); err != nil {
+		t.Fatal(err)
+	}
+	return s, db
+}
+
+// openTestDB writes the tweets to a new SQLite database and opens it.
+func openTestDB(t *testing.T, tweets []ScoredTweet) *sql.DB {
+	path := filepath.Join(t.TempDir(), "tweets.db")
+	s, err := OpenSQLiteSink(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tweet := range tweets {
+		s.Write(tweet)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := openSQLite(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// queryTweet returns a scored tweet sent at the given RFC 3339 time that
+// mentions the terms given as aspects.
+func queryTweet(id, created string, sentiment float64, terms ...Aspect) ScoredTweet {
+	c, err := time.Parse(time.RFC3339, created)
+	if err != nil {
+		panic(err)
+	}
+	var tweet ScoredTweet
+	tweet.ID = id
+	tweet.CreatedAt = c.Format(twitterTimeLayout)
+	tweet.Sentiment = sentiment
+	tweet.Label = Label(sentiment)
+	for _, a := range terms {
+		a.Kind = aspectTerm
+		tweet.Aspects = append(tweet.Aspects, a)
+	}
+	return tweet
+}
+
+func mustParseTime(t *testing.T, s string) time.Time {
+	parsed, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return parsed
+}
+
+// tableLines returns the lines of the table printed by printAggregates,
+// with the columns separated by single spaces.
+func tableLines(out string) []string {
+	var lines []string
+	for _, line := range strings.Split(out, "\n")[2:] {
+		if line != "" {
+			lines = append(lines, strings.Join(strings.Fields(line), " "))
+		}
+	}
+	return lines
+}
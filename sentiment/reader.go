@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/garyburd/go-oauth/oauth"
+)
+
+// filterURL is Twitter's statuses/filter streaming endpoint.
+const filterURL = "https://stream.twitter.com/1.1/statuses/filter.json"
+
+// Tweet is a single tweet.
+type Tweet struct {
+	ID              string `json:"id_str"`
+	CreatedAt       string `json:"created_at,omitempty"`
+	Text            string `json:"text"`
+	Lang            string `json:"lang,omitempty"`
+	User            User   `json:"user"`
+	InReplyToUserID string `json:"in_reply_to_user_id_str,omitempty"`
+	Coordinates     *Point `json:"coordinates,omitempty"`
+	Place           *Place `json:"place,omitempty"`
+	RetweetCount    int    `json:"retweet_count,omitempty"`
+	FavoriteCount   int    `json:"favorite_count,omitempty"`
+
+	// Terms are the tracked terms the tweet mentions, and Matched the
+	// filter predicates it matched, as worked out by Filter.Match.
+	Terms   []string `json:"terms,omitempty"`
+	Matched []string `json:"matched,omitempty"`
+
+	// BotScore is how likely the tweet is to come from a bot, from 0 to 1,
+	// once BotDetector has scored it.
+	BotScore *float64 `json:"bot_score,omitempty"`
+
+	// Seq is the order in which the tweet arrived on the stream, starting
+	// at 1.
+	Seq uint64 `json:"-"`
+
+	// ack acknowledges the tweet to the source it came from, if it needs
+	// to be, and progress tells the source it is still being worked on.
+	ack      func() error
+	progress func() error
+}
+
+// Done tells the source the tweet came from that it has been dealt with,
+// i.e., scored, skipped or dead-lettered, so it isn't delivered again.
+func (t Tweet) Done() {
+	if t.ack == nil {
+		return
+	}
+	if err := t.ack(); err != nil {
+		fmt.Println("Error acknowledging tweet:", err)
+	}
+}
+
+// Progress tells the source the tweet came from that it is still being
+// worked on, e.g., while it is parked waiting for a circuit breaker, so it
+// isn't delivered again in the meantime. It can be called as often as
+// needed; the source decides how often to pass it on.
+func (t Tweet) Progress() {
+	if t.progress == nil {
+		return
+	}
+	if err := t.progress(); err != nil {
+		fmt.Println("Error extending tweet's acknowledgement deadline:", err)
+	}
+}
+
+// streamMessage is a message on the stream: either a tweet, or one of the
+// control messages Twitter mixes in with them.
+type streamMessage struct {
+	Tweet
+	Delete         json.RawMessage `json:"delete"`
+	ScrubGeo       json.RawMessage `json:"scrub_geo"`
+	Limit          json.RawMessage `json:"limit"`
+	StatusWithheld json.RawMessage `json:"status_withheld"`
+	UserWithheld   json.RawMessage `json:"user_withheld"`
+	Disconnect     json.RawMessage `json:"disconnect"`
+	Warning        json.RawMessage `json:"warning"`
+}
+
+// controlKind returns the kind of control message m is, or "" if it is a
+// tweet.
+func (m *streamMessage) controlKind() string {
+	switch {
+	case m.Delete != nil:
+		return "delete"
+	case m.ScrubGeo != nil:
+		return "scrub_geo"
+	case m.Limit != nil:
+		return "limit"
+	case m.StatusWithheld != nil:
+		return "status_withheld"
+	case m.UserWithheld != nil:
+		return "user_withheld"
+	case m.Disconnect != nil:
+		return "disconnect"
+	case m.Warning != nil:
+		return "warning"
+	}
+	return ""
+}
+
+// User is the author of a tweet.
+type User struct {
+	ID         string `json:"id_str"`
+	ScreenName string `json:"screen_name"`
+
+	// The account details used to spot bots.
+	CreatedAt           string `json:"created_at,omitempty"`
+	FollowersCount      int    `json:"followers_count,omitempty"`
+	FriendsCount        int    `json:"friends_count,omitempty"`
+	StatusesCount       int    `json:"statuses_count,omitempty"`
+	DefaultProfile      bool   `json:"default_profile,omitempty"`
+	DefaultProfileImage bool   `json:"default_profile_image,omitempty"`
+	Verified            bool   `json:"verified,omitempty"`
+}
+
+// Point is a GeoJSON point. The coordinates are longitude then latitude.
+type Point struct {
+	Coordinates [2]float64 `json:"coordinates"`
+}
+
+// Place is a place a tweet is associated with.
+type Place struct {
+	FullName    string `json:"full_name"`
+	BoundingBox struct {
+		Coordinates [][][2]float64 `json:"coordinates"`
+	} `json:"bounding_box"`
+}
+
+// Location returns where the tweet was sent from: its exact coordinates
+// if it has them, otherwise the centre of its place.
+func (t Tweet) Location() (lng, lat float64, ok bool) {
+	if t.Coordinates != nil {
+		return t.Coordinates.Coordinates[0], t.Coordinates.Coordinates[1], true
+	}
+	if t.Place == nil || len(t.Place.BoundingBox.Coordinates) == 0 {
+		return 0, 0, false
+	}
+	ring := t.Place.BoundingBox.Coordinates[0]
+	if len(ring) == 0 {
+		return 0, 0, false
+	}
+	for _, c := range ring {
+		lng += c[0]
+		lat += c[1]
+	}
+	return lng / float64(len(ring)), lat / float64(len(ring)), true
+}
+
+// Time returns when the tweet was sent, or false if Twitter didn't say.
+func (t Tweet) Time() (time.Time, bool) {
+	created, err := time.Parse(twitterTimeLayout, t.CreatedAt)
+	return created, err == nil
+}
+
+// TweetReader includes the info we need to access Twitter.
+type TweetReader struct {
+	ConsumerKey, ConsumerSecret, AccessToken, AccessSecret string
+	Client                                                 *http.Client
+
+	// seq numbers the tweets in arrival order, across reconnections.
+	seq uint64
+}
+
+// NewTweetReader creates a new TweetReader with the given credentials.
+func NewTweetReader(consumerKey, consumerSecret, accessToken, accessSecret string) *TweetReader {
+	return &TweetReader{
+		ConsumerKey:    consumerKey,
+		ConsumerSecret: consumerSecret,
+		AccessToken:    accessToken,
+		AccessSecret:   accessSecret,
+		Client:         NewStreamingClient(),
+	}
+}
+
+// StatusError is an unexpected HTTP status from the streaming API.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected HTTP status code: %d", e.StatusCode)
+}
+
+// ErrDisconnected is returned when Twitter sends a disconnect message.
+var ErrDisconnected = errors.New("stream disconnected by Twitter")
+
+// connect opens a stream of the tweets matching the filter.
+func (r *TweetReader) connect(ctx context.Context, filter Filter) (*http.Response, error) {
+
+	// Create oauth Credentials.
+	creds := &oauth.Credentials{
+		Token:  r.AccessToken,
+		Secret: r.AccessSecret,
+	}
+
+	// Create an oauth Client.
+	authClient := &oauth.Client{
+		Credentials: oauth.Credentials{
+			Token:  r.ConsumerKey,
+			Secret: r.ConsumerSecret,
+		},
+	}
+
+	// Prepare the query.
+	form := filter.Values()
+	formEnc := form.Encode()
+	u, err := url.Parse(filterURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// Prepare the request.
+	req, err := http.NewRequestWithContext(ctx, "POST", u.String(), strings.NewReader(formEnc))
+	if err != nil {
+		return nil, fmt.Errorf("creating filter request failed: %v", err)
+	}
+	req.Header.Set("Authorization", authClient.AuthorizationHeader(creds, "POST", u, form))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Content-Length", strconv.Itoa(len(formEnc)))
+
+	// Execute the request.
+	resp, err := r.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// read sends the tweets on the stream that match the filter on the tweets
+// channel until the stream ends or the context is done.
+func (r *TweetReader) read(ctx context.Context, body io.Reader, filter Filter, tweets chan<- Tweet) error {
+
+	// Decode the results.
+	decoder := json.NewDecoder(body)
+	for {
+		var m streamMessage
+		if err := decoder.Decode(&m); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		// Count the control messages mixed in with the tweets, and stop if
+		// Twitter is disconnecting us.
+		if kind := m.controlKind(); kind != "" {
+			controlMessages.WithLabelValues(kind).Inc()
+			if kind == "disconnect" {
+				return fmt.Errorf("%w: %s", ErrDisconnected, m.Disconnect)
+			}
+			continue
+		}
+		tweetsReceived.Inc()
+
+		t := m.Tweet
+		if !filter.Match(&t) {
+			continue
+		}
+		r.seq++
+		t.Seq = r.seq
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case tweets <- t:
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import "sync"
+
+// RecentTweets keeps the last few scored tweets. It is safe for concurrent
+// use.
+type RecentTweets struct {
+	mux    sync.Mutex
+	tweets []ScoredTweet
+	next   int
+	full   bool
+}
+
+// NewRecentTweets creates a RecentTweets keeping the last n tweets.
+func NewRecentTweets(n int) *RecentTweets {
+	return &RecentTweets{tweets: make([]ScoredTweet, n)}
+}
+
+// Add adds a scored tweet, dropping the oldest if we are full.
+func (r *RecentTweets) Add(t ScoredTweet) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	r.tweets[r.next] = t
+	r.next = (r.next + 1) % len(r.tweets)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// Latest returns up to n of the most recent tweets, newest first.
+func (r *RecentTweets) Latest(n int) []ScoredTweet {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	have := r.next
+	if r.full {
+		have = len(r.tweets)
+	}
+	if n > have {
+		n = have
+	}
+	latest := make([]ScoredTweet, 0, n)
+	for i := 1; i <= n; i++ {
+		latest = append(latest, r.tweets[(r.next-i+len(r.tweets))%len(r.tweets)])
+	}
+	return latest
+}
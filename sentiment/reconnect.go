@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Twitter's reconnect etiquette for the streaming API: back off linearly
+// on network errors, exponentially on HTTP errors and more so when rate
+// limited, and treat a stream with no data (not even the keep-alive
+// newlines sent every 30 seconds) for 90 seconds as stalled. We also leave
+// a gap between the reconnections made to change the filter, as frequent
+// reconnects get rate limited too.
+const (
+	networkBackoffStep    = 250 * time.Millisecond
+	networkBackoffMax     = 16 * time.Second
+	httpBackoffStart      = 5 * time.Second
+	httpBackoffMax        = 320 * time.Second
+	rateLimitBackoffStart = time.Minute
+	rateLimitBackoffMax   = 16 * time.Minute
+	stallTimeout          = 90 * time.Second
+	filterChangeInterval  = 10 * time.Second
+)
+
+// errStalled is returned when the stream has sent nothing for too long.
+var errStalled = fmt.Errorf("no data on the stream for %v", stallTimeout)
+
+// Run streams the tweets matching the live filter on the tweets channel
+// until the context is done, reconnecting when the filter changes or the
+// stream fails. It only gives up on errors retrying won't fix, like bad
+// credentials.
+func (r *TweetReader) Run(ctx context.Context, live *LiveFilter, tweets chan<- Tweet) error {
+	var backoff streamBackoff
+	var connected time.Time
+	for {
+		filter, changed := live.Get()
+
+		// Stream until the filter changes or the stream fails.
+		connCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-changed:
+				cancel()
+			case <-connCtx.Done():
+			}
+		}()
+		resp, err := r.connect(connCtx, filter)
+		if err == nil {
+			connected = time.Now()
+			backoff.reset()
+			fmt.Printf("Connected to the stream, tracking %s\n", strings.Join(filter.Track, ", "))
+			body := newStallReader(resp.Body, stallTimeout, cancel)
+			err = r.read(connCtx, body, filter, tweets)
+			body.Stop()
+			if body.Stalled() {
+				err = errStalled
+			}
+			resp.Body.Close()
+		}
+		cancel()
+
+		// Work out how long to wait before reconnecting.
+		var wait time.Duration
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-changed:
+			fmt.Println("The tracked terms changed, reconnecting...")
+			wait = time.Until(connected.Add(filterChangeInterval))
+		default:
+			var retry bool
+			if wait, retry = backoff.next(err); !retry {
+				return err
+			}
+			fmt.Printf("Error streaming tweets: %v (reconnecting in %v)\n", err, wait)
+		}
+		if wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+		}
+	}
+}
+
+// streamBackoff works out how long to wait before reconnecting after an
+// error, following Twitter's guidelines.
+type streamBackoff struct {
+	kind  string
+	delay time.Duration
+}
+
+// reset starts the backoff again, after a successful connection.
+func (b *streamBackoff) reset() {
+	*b = streamBackoff{}
+}
+
+// next returns how long to wait after err, and false if reconnecting
+// won't help.
+func (b *streamBackoff) next(err error) (time.Duration, bool) {
+	var status *StatusError
+	switch {
+	case errors.As(err, &status):
+		switch status.StatusCode {
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound,
+			http.StatusNotAcceptable, http.StatusRequestEntityTooLarge,
+			http.StatusRequestedRangeNotSatisfiable:
+			return 0, false
+		case 420, http.StatusTooManyRequests:
+			return b.exponential("rate limit", rateLimitBackoffStart, rateLimitBackoffMax), true
+		}
+		return b.exponential("http", httpBackoffStart, httpBackoffMax), true
+	case errors.Is(err, ErrDisconnected):
+		return b.exponential("http", httpBackoffStart, httpBackoffMax), true
+	}
+
+	// Anything else is a network error, including the stream ending.
+	if b.kind != "network" {
+		b.kind, b.delay = "network", 0
+	}
+	if b.delay += networkBackoffStep; b.delay > networkBackoffMax {
+		b.delay = networkBackoffMax
+	}
+	return b.delay, true
+}
+
+// exponential doubles the delay for the kind of error, starting again if
+// the last error was of a different kind.
+func (b *streamBackoff) exponential(kind string, start, max time.Duration) time.Duration {
+	if b.kind != kind {
+		b.kind, b.delay = kind, start
+		return b.delay
+	}
+	if b.delay *= 2; b.delay > max {
+		b.delay = max
+	}
+	return b.delay
+}
+
+// stallReader cancels the stream if nothing is read from it for the
+// timeout.
+type stallReader struct {
+	r       io.Reader
+	timeout time.Duration
+	timer   *time.Timer
+	stalled atomic.Bool
+}
+
+// newStallReader wraps r, calling cancel if it stalls.
+func newStallReader(r io.Reader, timeout time.Duration, cancel context.CancelFunc) *stallReader {
+	s := &stallReader{r: r, timeout: timeout}
+	s.timer = time.AfterFunc(timeout, func() {
+		s.stalled.Store(true)
+		cancel()
+	})
+	return s
+}
+
+func (s *stallReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	if n > 0 {
+		s.timer.Reset(s.timeout)
+	}
+	return n, err
+}
+
+// Stop stops watching for a stall.
+func (s *stallReader) Stop() {
+	s.timer.Stop()
+}
+
+// Stalled reports whether the stream was cancelled for stalling.
+func (s *stallReader) Stalled() bool {
+	return s.stalled.Load()
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestStreamBackoff(t *testing.T) {
+	network := errors.New("connection reset")
+	serverError := &StatusError{StatusCode: 503}
+	rateLimited := &StatusError{StatusCode: 420}
+	repeat := func(err error, n int) []error {
+		var errs []error
+		for i := 0; i < n; i++ {
+			errs = append(errs, err)
+		}
+		return errs
+	}
+	s := time.Second
+	tests := []struct {
+		name      string
+		errs      []error
+		want      []time.Duration
+		wantRetry bool
+	}{
+		{
+			name:      "network errors back off linearly",
+			errs:      repeat(network, 4),
+			want:      []time.Duration{250 * time.Millisecond, 500 * time.Millisecond, 750 * time.Millisecond, s},
+			wantRetry: true,
+		},
+		{
+			name:      "network backoff is capped",
+			errs:      repeat(network, 70),
+			want:      append(linearBackoffs(64), repeatDuration(16*s, 6)...),
+			wantRetry: true,
+		},
+		{
+			name:      "http errors back off exponentially",
+			errs:      repeat(serverError, 8),
+			want:      []time.Duration{5 * s, 10 * s, 20 * s, 40 * s, 80 * s, 160 * s, 320 * s, 320 * s},
+			wantRetry: true,
+		},
+		{
+			name:      "disconnects back off like http errors",
+			errs:      repeat(ErrDisconnected, 2),
+			want:      []time.Duration{5 * s, 10 * s},
+			wantRetry: true,
+		},
+		{
+			name:      "rate limits back off from a minute",
+			errs:      append(repeat(rateLimited, 5), &StatusError{StatusCode: 429}),
+			want:      []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 8 * time.Minute, 16 * time.Minute, 16 * time.Minute},
+			wantRetry: true,
+		},
+		{
+			name:      "a different kind of error starts again",
+			errs:      []error{serverError, serverError, network, serverError},
+			want:      []time.Duration{5 * s, 10 * s, 250 * time.Millisecond, 5 * s},
+			wantRetry: true,
+		},
+		{
+			name: "bad credentials aren't retried",
+			errs: []error{&StatusError{StatusCode: 401}},
+			want: []time.Duration{0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b streamBackoff
+			var got []time.Duration
+			var retry bool
+			for _, err := range tt.errs {
+				var wait time.Duration
+				wait, retry = b.next(err)
+				got = append(got, wait)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got waits %v, want %v", got, tt.want)
+			}
+			if retry != tt.wantRetry {
+				t.Errorf("got retry %v, want %v", retry, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestStreamBackoffReset(t *testing.T) {
+	var b streamBackoff
+	b.next(&StatusError{StatusCode: 503})
+	b.next(&StatusError{StatusCode: 503})
+	b.reset()
+	if wait, _ := b.next(&StatusError{StatusCode: 503}); wait != httpBackoffStart {
+		t.Errorf("got %v after a reset, want %v", wait, httpBackoffStart)
+	}
+}
+
+// linearBackoffs returns the first n network backoffs.
+func linearBackoffs(n int) []time.Duration {
+	var waits []time.Duration
+	for i := 1; i <= n; i++ {
+		waits = append(waits, time.Duration(i)*networkBackoffStep)
+	}
+	return waits
+}
+
+// repeatDuration returns d n times.
+func repeatDuration(d time.Duration, n int) []time.Duration {
+	var waits []time.Duration
+	for i := 0; i < n; i++ {
+		waits = append(waits, d)
+	}
+	return waits
+}
+
+func TestStallReader(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	pr, pw := io.Pipe()
+	ctx, cancel := context.WithCancel(context.Background())
+	s := newStallReader(pr, timeout, cancel)
+	defer s.Stop()
+
+	// Keep the stream going for a few timeouts, then stop sending.
+	go func() {
+		for i := 0; i < 20; i++ {
+			pw.Write([]byte("\r\n"))
+			time.Sleep(timeout / 5)
+		}
+	}()
+	go io.Copy(io.Discard, s)
+
+	time.Sleep(2 * timeout)
+	if s.Stalled() || ctx.Err() != nil {
+		t.Fatal("stalled while data was arriving")
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(10 * timeout):
+		t.Fatal("didn't stall once the data stopped")
+	}
+	if !s.Stalled() {
+		t.Error("cancelled without being stalled")
+	}
+	pw.Close()
+}
+
+func TestStallReaderStop(t *testing.T) {
+	const timeout = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s := newStallReader(eofReader{}, timeout, cancel)
+	s.Stop()
+	time.Sleep(3 * timeout)
+	if s.Stalled() || ctx.Err() != nil {
+		t.Error("stalled after being stopped")
+	}
+}
+
+// eofReader is always at the end.
+type eofReader struct{}
+
+func (eofReader) Read(p []byte) (int, error) { return 0, io.EOF }
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"time"
+
+	"github.com/machinebox/sdk-go/textbox"
+)
+
+// ScoredTweet is a tweet along with the result of analyzing it.
+type ScoredTweet struct {
+	Tweet
+
+	// AnalyzedText is the normalized text that was analyzed. The original
+	// is in Text.
+	AnalyzedText string `json:"analyzed_text"`
+
+	// Sentiment is TextSentiment, the analyzer's score for the text, with
+	// the Emoji signal blended in.
+	TextSentiment float64     `json:"text_sentiment"`
+	Emoji         EmojiSignal `json:"emoji"`
+	Sentiment     float64     `json:"sentiment"`
+	Label         string      `json:"label"`
+
+	// Aspects are the sentiment towards each term and entity the tweet
+	// mentions.
+	Aspects []Aspect `json:"aspects,omitempty"`
+
+	// Account is "bot" or "human", or empty if bot detection is off, and
+	// Weight is how much the tweet counts towards the averages. Influence
+	// is how much more it counts towards the weighted averages.
+	Account   string  `json:"account,omitempty"`
+	Weight    float64 `json:"weight"`
+	Influence float64 `json:"influence"`
+
+	// Analyzer is the name of the analyzer that scored the tweet.
+	Analyzer string `json:"analyzer,omitempty"`
+
+	// Analysis is left out of the JSON, as it repeats the text.
+	Analysis *textbox.Analysis `json:"-"`
+
+	// released marks a stand-in for a tweet that was skipped, parked or
+	// dead-lettered rather than scored.
+	released bool
+}
+
+// releaseMarker returns a stand-in telling Reorder that the tweet with the
+// given sequence number won't be scored, so it needn't wait for it.
+func releaseMarker(seq uint64) ScoredTweet {
+	return ScoredTweet{Tweet: Tweet{Seq: seq}, released: true}
+}
+
+// Reorder puts scored tweets coming out of the workers back into the order
+// the tweets arrived in, using their sequence numbers. Tweets that won't be
+// scored are stood in for by release markers, which take their place in
+// the order and are then dropped. It holds at most window tweets. When the
+// window is full, or the oldest missing tweet has been waited on for longer
+// than gapTimeout, the gap is skipped. A tweet that shows up after its gap
+// was skipped, like a parked tweet that is retried, is sent on straight
+// away, out of order. Once in is closed, the tweets still held are sent on
+// in order and the output is closed too.
+func Reorder(in <-chan ScoredTweet, window int, gapTimeout time.Duration) <-chan ScoredTweet {
+	out := make(chan ScoredTweet)
+
+	go func() {
+		defer close(out)
+
+		pending := make(map[uint64]ScoredTweet)
+		next := uint64(1)
+		var waitingSince time.Time
+
+		ticker := time.NewTicker(gapTimeout / 4)
+		defer ticker.Stop()
+
+		// flush sends on every tweet we have in sequence.
+		flush := func() {
+			advanced := false
+			for {
+				t, ok := pending[next]
+				if !ok {
+					break
+				}
+				delete(pending, next)
+				next++
+				advanced = true
+				if !t.released {
+					out <- t
+				}
+			}
+
+			// Start the gap timer when we begin waiting on a new tweet.
+			switch {
+			case len(pending) == 0:
+				waitingSince = time.Time{}
+			case advanced || waitingSince.IsZero():
+				waitingSince = time.Now()
+			}
+		}
+
+		// skip gives up on the missing tweets before the oldest one we have.
+		skip := func() {
+			first := true
+			for seq := range pending {
+				if first || seq < next {
+					next = seq
+					first = false
+				}
+			}
+		}
+
+		for {
+			select {
+			case t, ok := <-in:
+				if !ok {
+
+					// Send on whatever is left, in order.
+					for len(pending) > 0 {
+						skip()
+						flush()
+					}
+					return
+				}
+
+				if t.Seq < next {
+					if !t.released {
+						out <- t
+					}
+					continue
+				}
+				pending[t.Seq] = t
+				if len(pending) > window {
+					skip()
+				}
+				flush()
+
+			case <-ticker.C:
+				if len(pending) > 0 && time.Since(waitingSince) >= gapTimeout {
+					skip()
+					flush()
+				}
+			}
+		}
+	}()
+
+	return out
+}
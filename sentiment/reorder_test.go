@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReorder(t *testing.T) {
+
+	// Sequence numbers prefixed with "r" are sent as release markers.
+	tests := []struct {
+		name   string
+		in     []string
+		window int
+		want   []uint64
+	}{
+		{
+			name:   "in order",
+			in:     []string{"1", "2", "3"},
+			window: 10,
+			want:   []uint64{1, 2, 3},
+		},
+		{
+			name:   "out of order",
+			in:     []string{"3", "1", "4", "2"},
+			window: 10,
+			want:   []uint64{1, 2, 3, 4},
+		},
+		{
+			name:   "release markers fill gaps and are dropped",
+			in:     []string{"3", "r2", "r1", "4"},
+			window: 10,
+			want:   []uint64{3, 4},
+		},
+		{
+			name:   "full window skips the gap",
+			in:     []string{"2", "3", "4", "1"},
+			window: 2,
+			want:   []uint64{2, 3, 4, 1},
+		},
+		{
+			name:   "late marker is dropped",
+			in:     []string{"2", "3", "4", "r1"},
+			window: 2,
+			want:   []uint64{2, 3, 4},
+		},
+		{
+			name:   "closing flushes what is held",
+			in:     []string{"5", "3"},
+			window: 10,
+			want:   []uint64{3, 5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := make(chan ScoredTweet, len(tt.in))
+			for _, s := range tt.in {
+				s, released := strings.CutPrefix(s, "r")
+				seq, err := strconv.ParseUint(s, 10, 64)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if released {
+					in <- releaseMarker(seq)
+					continue
+				}
+				var st ScoredTweet
+				st.Seq = seq
+				in <- st
+			}
+			close(in)
+
+			var got []uint64
+			for st := range Reorder(in, tt.window, time.Minute) {
+				got = append(got, st.Seq)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReorderGapTimeout(t *testing.T) {
+	in := make(chan ScoredTweet)
+	out := Reorder(in, 10, 20*time.Millisecond)
+
+	// Tweet 1 never arrives, so tweet 2 is sent once the gap times out.
+	var st ScoredTweet
+	st.Seq = 2
+	in <- st
+	select {
+	case got := <-out:
+		if got.Seq != 2 {
+			t.Errorf("got tweet %d, want 2", got.Seq)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("gap wasn't skipped")
+	}
+	close(in)
+	if _, ok := <-out; ok {
+		t.Error("output wasn't closed")
+	}
+}
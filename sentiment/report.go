@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// printStats prints a report of the stats.
+func printStats(s *Stats, cfg Config) {
+	weighted := len(cfg.Influence) > 0
+
+	fmt.Printf("Sentiment: %s\n", formatSentiment(&s.Breakdown, weighted))
+	fmt.Printf("Total tweets analyzed: %d\n", s.Counts["total"])
+	fmt.Printf("Total positive tweets: %d\n", s.Counts["positive"])
+	fmt.Printf("Total negative tweets: %d\n", s.Counts["negative"])
+	fmt.Printf("Total neutral tweets: %d\n", s.Counts["neutral"])
+	for _, lang := range sortedKeys(s.Languages) {
+		b := s.Languages[lang]
+		fmt.Printf("  %s: %d tweets, sentiment %s\n", lang, b.Counts["total"], formatSentiment(b, weighted))
+	}
+	for _, kind := range sortedKeys(s.Accounts) {
+		b := s.Accounts[kind]
+		fmt.Printf("  %ss: %d tweets, sentiment %s\n", kind, b.Counts["total"], formatSentiment(b, weighted))
+	}
+	if n := s.Dropped[skipBot]; n > 0 {
+		fmt.Printf("Tweets dropped as bots: %d\n", n)
+	}
+	for _, term := range sortedKeys(s.Terms) {
+		b := s.Terms[term]
+		fmt.Printf("Sentiment towards %s: %s (%d tweets)\n", term, formatSentiment(b, weighted), b.Counts["total"])
+	}
+	printUsers("Most active users", s.Users.MostActive(3), weighted)
+	printUsers("Most negative users", s.Users.MostNegative(3), weighted)
+	printUsers("Most positive users", s.Users.MostPositive(3), weighted)
+	for _, term := range sortedKeys(s.Terms) {
+		top := s.TopEmoji(term, 5)
+		if len(top) == 0 {
+			continue
+		}
+		var emoji []string
+		for _, e := range top {
+			emoji = append(emoji, fmt.Sprintf("%s %d", e.Emoji, e.Count))
+		}
+		fmt.Printf("Top emoji for %s: %s\n", term, strings.Join(emoji, ", "))
+	}
+}
+
+// printTrends prints the trending items.
+func printTrends(trends []Trend) {
+	if len(trends) == 0 {
+		return
+	}
+	fmt.Println("Trending:")
+	for _, t := range trends {
+		fmt.Printf("  %s (%s): %d tweets, %0.1fx usual, sentiment %0.2f\n", t.Text, t.Kind, t.Count, t.Ratio, t.SentimentAverage)
+	}
+}
+
+// printUsers prints a list of user profiles on one line.
+func printUsers(title string, profiles []UserProfile, weighted bool) {
+	if len(profiles) == 0 {
+		return
+	}
+	var users []string
+	for _, p := range profiles {
+		users = append(users, fmt.Sprintf("@%s (%d tweets, sentiment %s)", p.ScreenName, p.Count, formatSentiment(&p.Breakdown, weighted)))
+	}
+	fmt.Printf("%s: %s\n", title, strings.Join(users, ", "))
+}
+
+// formatSentiment formats the average sentiment of a breakdown, along with
+// the influence-weighted average if there is one.
+func formatSentiment(b *Breakdown, weighted bool) string {
+	if !weighted {
+		return fmt.Sprintf("%0.2f", b.SentimentAverage)
+	}
+	return fmt.Sprintf("%0.2f (influence-weighted %0.2f)", b.SentimentAverage, b.WeightedAverage)
+}
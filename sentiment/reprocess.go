@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// reprocess replays the dead-letter file through the analyzer and merges
+// the results into the saved stats. Tweets that fail again stay in the
+// dead-letter file with their attempt count bumped. Run it while the
+// stream is stopped, as it rewrites both files.
+func reprocess(cfg Config) error {
+	p, err := NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+	return replayDeadLetters(os.Stdout, p, cfg.DeadLetterPath, cfg.StatsPath)
+}
+
+// replayDeadLetters scores the dead letters in deadLetterPath with the
+// pipeline, merges them into the stats at statsPath and reports the
+// results to out.
+func replayDeadLetters(out io.Writer, p *Pipeline, deadLetterPath, statsPath string) error {
+
+	// Read the dead letters.
+	letters, err := ReadDeadLetters(deadLetterPath)
+	if err != nil {
+		return fmt.Errorf("reading dead letters: %v", err)
+	}
+	if len(letters) == 0 {
+		fmt.Fprintln(out, "No dead letters to reprocess")
+		return nil
+	}
+
+	// Load the stats we are merging into. The windowed stats are only
+	// carried over, as the tweets are too old for them.
+	windows := NewWindowedStats(windowBucket, windowBuckets)
+	myStats, err := LoadStats(statsPath, windows)
+	if err != nil {
+		return fmt.Errorf("loading stats: %v", err)
+	}
+
+	// Replay the tweets.
+	ctx := context.Background()
+	var failed []DeadLetter
+	for _, l := range letters {
+		scored, err := p.Score(ctx, l.Tweet)
+		var skip *SkipError
+		if errors.As(err, &skip) {
+
+			// Keep tweets we would skip right now, e.g., in languages we
+			// aren't analyzing.
+			failed = append(failed, l)
+			continue
+		}
+		if err != nil {
+			l.Error = err.Error()
+			l.Attempts += attempts(err)
+			l.Time = time.Now().UTC()
+			failed = append(failed, l)
+			continue
+		}
+		myStats.Record(scored)
+	}
+
+	// Save the stats before dropping the reprocessed tweets, so a crash in
+	// between can't lose them.
+	if err := myStats.Save(statsPath, windows); err != nil {
+		return fmt.Errorf("saving stats: %v", err)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, l := range failed {
+		if err := enc.Encode(l); err != nil {
+			return err
+		}
+	}
+	if err := writeFileAtomic(deadLetterPath, buf.Bytes()); err != nil {
+		return fmt.Errorf("rewriting dead letters: %v", err)
+	}
+
+	fmt.Fprintf(out, "Reprocessed %d tweets, %d still failing\n", len(letters)-len(failed), len(failed))
+	return nil
+}
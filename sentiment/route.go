@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// LanguageRouter picks the analyzer for a tweet based on its language.
+type LanguageRouter struct {
+
+	// Default analyzes the allowed languages that don't have an analyzer
+	// of their own.
+	Default *ResilientAnalyzer
+
+	// Analyzers are the language-specific analyzers.
+	Analyzers map[string]*ResilientAnalyzer
+
+	// Allowed are the languages Default analyzes. If it is empty, Default
+	// analyzes every language.
+	Allowed map[string]bool
+}
+
+// NewRouter creates the configured analyzer backends, each wrapped with
+// the default retry and circuit breaker policy.
+func NewRouter(cfg Config) (*LanguageRouter, error) {
+	if err := cfg.ValidateAnalyzer(); err != nil {
+		return nil, err
+	}
+
+	r := &LanguageRouter{
+		Default:   newBackend(cfg.Analyzer, cfg.MachineBox, cfg.Workers),
+		Analyzers: make(map[string]*ResilientAnalyzer),
+		Allowed:   make(map[string]bool),
+	}
+	for lang, addr := range cfg.LanguageAnalyzers {
+		r.Analyzers[lang] = newBackend(cfg.Analyzer, addr, cfg.Workers)
+	}
+	for _, lang := range cfg.Languages {
+		r.Allowed[lang] = true
+	}
+	return r, nil
+}
+
+// newBackend creates an analyzer backend at the given address, with
+// enough connections for every worker to call it at once, and as many
+// again for calls abandoned after a timeout that are still running.
+func newBackend(backend, addr string, workers int) *ResilientAnalyzer {
+	var a Analyzer
+	switch backend {
+	case "machinebox":
+		a = NewMachineBoxAnalyzer(addr, 2*workers)
+	}
+	r := NewResilientAnalyzer(a, nil, DefaultPolicy)
+	r.Name = backend + "@" + addr
+	return r
+}
+
+// For returns the analyzer for a language, or false if tweets in that
+// language should be skipped.
+func (r *LanguageRouter) For(lang string) (*ResilientAnalyzer, bool) {
+	if a, ok := r.Analyzers[lang]; ok {
+		return a, true
+	}
+	if len(r.Allowed) == 0 || r.Allowed[lang] {
+		return r.Default, true
+	}
+	return nil, false
+}
+
+// drainParked feeds tweets that were parked while their analyzer's breaker
+// was open back to the workers. While a breaker is open its tweets wait,
+// once it is ready to probe a single tweet is sent, and once it has closed
+// the rest follow. Tweets left waiting tell their source they are still
+// in progress, so it doesn't deliver them again. Tweets that can't be put
+// back, or are held when ctx is done, are dead-lettered.
+func drainParked(ctx context.Context, router *LanguageRouter, parked chan Tweet, tweets chan<- Tweet, deadLetters *DeadLetterSink) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Go through the tweets parked so far once.
+		probed := make(map[*CircuitBreaker]bool)
+		for n := len(parked); n > 0; n-- {
+			var t Tweet
+			select {
+			case t = <-parked:
+			default:
+				continue
+			}
+
+			// Tweets are only parked once they have an analyzer.
+			a, _ := router.For(t.Lang)
+
+			// Put it back if its analyzer isn't ready, or we've already
+			// sent it a probe.
+			b := a.Breaker
+			if !b.Ready() || probed[b] {
+				t.Progress()
+				select {
+				case parked <- t:
+				default:
+					deadLetterParked(deadLetters, t)
+				}
+				continue
+			}
+			if !b.Closed() {
+				probed[b] = true
+			}
+
+			select {
+			case <-ctx.Done():
+				deadLetterParked(deadLetters, t)
+				return
+			case tweets <- t:
+			}
+		}
+	}
+}
+
+// deadLetterParked dead-letters a parked tweet that won't be retried.
+func deadLetterParked(deadLetters *DeadLetterSink, t Tweet) {
+	if err := deadLetters.Write(t, ErrCircuitOpen); err != nil {
+		fmt.Println("Error writing dead letter:", err)
+		return
+	}
+	t.Done()
+}
@@ -0,0 +1,45 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestDrainParkedProgress(t *testing.T) {
+	a := NewResilientAnalyzer(&fakeAnalyzer{}, nil, Policy{FailureThreshold: 1, OpenTimeout: time.Hour})
+	a.Breaker.Failure()
+	router := &LanguageRouter{Default: a}
+
+	// The tweet stays parked while the breaker is open, and keeps telling
+	// its source it is in progress.
+	progressed := make(chan struct{}, 10)
+	tw := Tweet{ID: "1", progress: func() error {
+		progressed <- struct{}{}
+		return nil
+	}}
+	parked := make(chan Tweet, 1)
+	parked <- tw
+	tweets := make(chan Tweet)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		drainParked(ctx, router, parked, tweets, nil)
+		close(done)
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case <-progressed:
+		case tw := <-tweets:
+			t.Fatalf("got tweet %q while the breaker was open", tw.ID)
+		case <-time.After(5 * time.Second):
+			t.Fatal("parked tweet didn't report progress")
+		}
+	}
+	cancel()
+	<-done
+	if len(parked) != 1 {
+		t.Error("tweet was taken off the parked queue")
+	}
+}
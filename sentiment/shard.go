@@ -0,0 +1,52 @@
+package main
+
+// StatsShard accumulates stats for a single worker.
+type StatsShard struct {
+	Stats
+
+	// Keep shards on separate cache lines so workers don't slow each other
+	// down by writing to neighbouring shards.
+	_ [64]byte
+}
+
+// ShardedStats splits the stats into one shard per worker, so workers only
+// ever lock their own shard. The shards are merged into a Stats on top of
+// the base stats (e.g., those saved by a previous run) when a snapshot is
+// taken.
+type ShardedStats struct {
+	base   *Stats
+	shards []StatsShard
+}
+
+// NewShardedStats creates a ShardedStats with n shards on top of the base
+// stats.
+func NewShardedStats(base *Stats, n int) *ShardedStats {
+	s := &ShardedStats{
+		base:   base,
+		shards: make([]StatsShard, n),
+	}
+	for i := range s.shards {
+		s.shards[i].Stats.init()
+	}
+	return s
+}
+
+// Shard returns the i'th shard. Each worker should use its own.
+func (s *ShardedStats) Shard(i int) *StatsShard {
+	return &s.shards[i%len(s.shards)]
+}
+
+// Snapshot merges the shards into a new Stats.
+func (s *ShardedStats) Snapshot() *Stats {
+	snapshot := NewStats()
+	snapshot.Merge(s.base)
+	for i := range s.shards {
+		snapshot.Merge(&s.shards[i].Stats)
+	}
+	return snapshot
+}
+
+// Track starts empty stats for newly tracked terms.
+func (s *ShardedStats) Track(terms []string) {
+	s.base.Track(terms)
+}
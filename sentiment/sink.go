@@ -0,0 +1,282 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Sink receives every scored tweet, e.g., to save it for later analysis.
+// Close is called once the last tweet has been written, and should flush
+// anything buffered.
+type Sink interface {
+	Write(t ScoredTweet) error
+	Close() error
+}
+
+// StatsSink is a Sink that also receives a snapshot of the stats every
+// report interval.
+type StatsSink interface {
+	Sink
+	WriteStats(s *Stats) error
+}
+
+// OpenSinks opens the configured sinks. Outputs are given as format:path,
+// e.g., jsonl:results/tweets.
+func OpenSinks(cfg Config) ([]Sink, error) {
+	var sinks []Sink
+	closeAll := func() {
+		for _, s := range sinks {
+			s.Close()
+		}
+	}
+
+	if cfg.SQLitePath != "" {
+		s, err := OpenSQLiteSink(cfg.SQLitePath)
+		if err != nil {
+			return nil, fmt.Errorf("opening SQLite database: %v", err)
+		}
+		sinks = append(sinks, s)
+	}
+	for _, output := range cfg.Outputs {
+		format, path, _ := strings.Cut(output, ":")
+		s, err := NewFileSink(path, format, FileSinkOptions{
+			MaxSize:       cfg.RotateSize,
+			MaxAge:        cfg.RotateInterval,
+			FlushInterval: cfg.FlushInterval,
+		})
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("opening output %s: %v", output, err)
+		}
+		sinks = append(sinks, s)
+	}
+	if cfg.NATSURL != "" {
+		s, err := OpenNATSSink(cfg.NATSURL, cfg.NATSSubject, cfg.NATSStream, cfg.NATSBuffer)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("connecting to NATS: %v", err)
+		}
+		sinks = append(sinks, s)
+	}
+	return sinks, nil
+}
+
+// validateOutputs checks the format:path outputs.
+func validateOutputs(outputs []string) error {
+	for _, output := range outputs {
+		format, path, ok := strings.Cut(output, ":")
+		if !ok || path == "" {
+			return fmt.Errorf("output %q is not a format:path pair like jsonl:results/tweets", output)
+		}
+		if _, ok := fileFormats[format]; !ok {
+			return fmt.Errorf("output %q: unknown format %q (%s)", output, format, strings.Join(fileFormatNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// fileFormatNames returns the names of the file formats, sorted.
+func fileFormatNames() []string {
+	var names []string
+	for name := range fileFormats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// FileSinkOptions control when a FileSink starts a new file and flushes
+// the current one.
+type FileSinkOptions struct {
+
+	// MaxSize is the size in bytes, and MaxAge the age, at which a new
+	// file is started. Either may be 0 to turn it off.
+	MaxSize int64
+	MaxAge  time.Duration
+
+	// FlushInterval is how often buffered tweets are written to the file.
+	FlushInterval time.Duration
+}
+
+// FileSink writes scored tweets to files in one of the fileFormats. The
+// files are named after the path, the time they were started and the
+// format, like results/tweets-20180301T120000Z.jsonl. A file has a .part
+// suffix until it is finished, when a new one is started or the sink is
+// closed. Tweets are queued and written by a background goroutine.
+type FileSink struct {
+	path    string
+	format  string
+	options FileSinkOptions
+	tweets  chan ScoredTweet
+	done    chan struct{}
+	err     error
+
+	// The current file, if one is open.
+	file    *os.File
+	name    string
+	opened  time.Time
+	counter *countingWriter
+	records recordWriter
+}
+
+// NewFileSink creates a FileSink writing to files named after path in the
+// given format. The first file is created with the first tweet.
+func NewFileSink(path, format string, options FileSinkOptions) (*FileSink, error) {
+	if _, ok := fileFormats[format]; !ok {
+		return nil, fmt.Errorf("unknown format %q (%s)", format, strings.Join(fileFormatNames(), ", "))
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if options.FlushInterval <= 0 {
+		options.FlushInterval = time.Second
+	}
+	s := &FileSink{
+		path:    path,
+		format:  format,
+		options: options,
+		tweets:  make(chan ScoredTweet, 1000),
+		done:    make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues a scored tweet to be written.
+func (s *FileSink) Write(t ScoredTweet) error {
+	s.tweets <- t
+	return nil
+}
+
+// Close writes the queued tweets and finishes the current file. It
+// returns the first error from closing the file.
+func (s *FileSink) Close() error {
+	close(s.tweets)
+	<-s.done
+	return s.err
+}
+
+// run writes the queued tweets until the sink is closed.
+func (s *FileSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.options.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case t, ok := <-s.tweets:
+			if !ok {
+				s.err = s.finish()
+				return
+			}
+			if err := s.write(t); err != nil {
+				fmt.Printf("Error writing to %s: %v\n", s.name, err)
+			}
+		case now := <-ticker.C:
+			if s.file == nil {
+				continue
+			}
+			var err error
+			if s.options.MaxAge > 0 && now.Sub(s.opened) >= s.options.MaxAge {
+				err = s.finish()
+			} else {
+				err = s.flush()
+			}
+			if err != nil {
+				fmt.Printf("Error writing to %s: %v\n", s.name, err)
+			}
+		}
+	}
+}
+
+// write writes a tweet, starting a new file first if needed.
+func (s *FileSink) write(t ScoredTweet) error {
+	if s.file != nil && s.options.MaxSize > 0 && s.size() >= s.options.MaxSize {
+		if err := s.finish(); err != nil {
+			return err
+		}
+	}
+	if s.file == nil {
+		if err := s.open(); err != nil {
+			return err
+		}
+	}
+	return s.records.Write(t)
+}
+
+// open starts a new file.
+func (s *FileSink) open() error {
+	now := time.Now().UTC()
+	base := s.path + "-" + now.Format("20060102T150405Z")
+	name := base + "." + s.format
+	for i := 2; ; i++ {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			break
+		}
+		name = fmt.Sprintf("%s-%d.%s", base, i, s.format)
+	}
+
+	f, err := os.OpenFile(name+".part", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	counter := &countingWriter{w: f}
+	records, err := fileFormats[s.format](counter)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	s.file, s.name, s.opened, s.counter, s.records = f, name, now, counter, records
+	return nil
+}
+
+// size returns the size of the current file, counting the tweets still
+// buffered in memory.
+func (s *FileSink) size() int64 {
+	return s.counter.n + s.records.Buffered()
+}
+
+// flush writes the buffered tweets to the current file.
+func (s *FileSink) flush() error {
+	return s.records.Flush()
+}
+
+// finish closes the current file and gives it its final name.
+func (s *FileSink) finish() error {
+	if s.file == nil {
+		return nil
+	}
+	f, name, records := s.file, s.name, s.records
+	s.file, s.records = nil, nil
+
+	err := records.Close()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), name)
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestFileSinkRotation(t *testing.T) {
+	var tweet ScoredTweet
+	tweet.ID = "1"
+	tweet.Text = "I love this"
+	tweet.Sentiment = 0.9
+	line, err := json.Marshal(tweet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lineSize := int64(len(line) + 1)
+
+	tests := []struct {
+		name      string
+		format    string
+		maxSize   int64
+		tweets    int
+		wantFiles int
+	}{
+		{"no rotation", "jsonl", 0, 7, 1},
+		{"file per tweet", "jsonl", 1, 3, 3},
+		{"three tweets per file", "jsonl", 3 * lineSize, 7, 3},
+		{"csv file per tweet", "csv", 1, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			s, err := NewFileSink(filepath.Join(dir, "tweets"), tt.format, FileSinkOptions{MaxSize: tt.maxSize})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.tweets; i++ {
+				s.Write(tweet)
+			}
+			if err := s.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err := filepath.Glob(filepath.Join(dir, "*"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != tt.wantFiles {
+				t.Errorf("got %d files, want %d: %q", len(files), tt.wantFiles, files)
+			}
+			total := 0
+			for _, f := range files {
+				if !strings.HasSuffix(f, "."+tt.format) {
+					t.Errorf("file %s wasn't finished", f)
+				}
+				n := countLines(t, f)
+				if tt.format == "csv" {
+					n-- // the header
+				}
+				total += n
+			}
+			if total != tt.tweets {
+				t.Errorf("got %d tweets, want %d", total, tt.tweets)
+			}
+		})
+	}
+}
+
+func TestFileSinkAge(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(filepath.Join(dir, "tweets"), "jsonl", FileSinkOptions{
+		MaxAge:        50 * time.Millisecond,
+		FlushInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var tweet ScoredTweet
+	s.Write(tweet)
+
+	// The tweet is flushed to the .part file before the file is finished.
+	time.Sleep(20 * time.Millisecond)
+	parts, _ := filepath.Glob(filepath.Join(dir, "*.part"))
+	if len(parts) != 1 || countLines(t, parts[0]) != 1 {
+		t.Errorf("tweet wasn't flushed to %q", parts)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	s.Write(tweet)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.jsonl"))
+	if len(files) != 2 {
+		t.Errorf("got files %q, want 2", files)
+	}
+}
+
+func TestFileSinkParquetRowGroups(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileSink(filepath.Join(dir, "tweets"), "parquet", FileSinkOptions{
+		FlushInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Flushing many times while the tweets arrive still leaves them all
+	// in one row group.
+	var tweet ScoredTweet
+	for i := 0; i < 5; i++ {
+		tweet.ID = fmt.Sprint(i)
+		s.Write(tweet)
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.parquet"))
+	if len(files) != 1 {
+		t.Fatalf("got files %q, want 1", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := buffer.NewBufferFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pr, err := reader.NewParquetReader(f, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pr.ReadStop()
+	if got := pr.GetNumRows(); got != 5 {
+		t.Errorf("got %d tweets, want 5", got)
+	}
+	if got := len(pr.Footer.RowGroups); got != 1 {
+		t.Errorf("got %d row groups, want 1", got)
+	}
+}
+
+func countLines(t *testing.T, name string) int {
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	n := 0
+	for scanner := bufio.NewScanner(f); scanner.Scan(); {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+// TweetSource sends tweets on the tweets channel until the context is done,
+// setting the tracked terms they mention from the live filter. A
+// TweetReader streams them from Twitter, and a NATSSource consumes them
+// from NATS.
+type TweetSource interface {
+	Run(ctx context.Context, live *LiveFilter, tweets chan<- Tweet) error
+}
+
+// NewTweetSource creates the configured source of tweets. Sources that
+// hold a connection, like NATSSource, are also io.Closers, and should be
+// closed once the last tweet is done.
+func NewTweetSource(cfg Config) (TweetSource, error) {
+	if cfg.InputNATS != "" {
+		return OpenNATSSource(cfg.InputNATS, cfg.InputSubject, cfg.InputStream, cfg.InputGroup)
+	}
+	return NewTweetReader(cfg.Twitter.ConsumerKey, cfg.Twitter.ConsumerSecret, cfg.Twitter.AccessToken, cfg.Twitter.AccessSecret), nil
+}
+
+// A tweet consumed from NATS is delivered again if it isn't acknowledged
+// within natsAckWait (see NATSSource.AckWait), and at most
+// natsMaxAckPending tweets are delivered to the group without being
+// acknowledged.
+const (
+	natsAckWait       = 5 * time.Minute
+	natsMaxAckPending = 1000
+)
+
+// NATSSource consumes tweets, as Twitter's JSON, from a JetStream stream.
+// Every analyzer using the same Group shares a durable consumer, so each
+// tweet goes to just one of them. A tweet is only acknowledged once it has
+// been scored, skipped or dead-lettered, so tweets in flight when an
+// analyzer stops are delivered again, to it or another analyzer.
+type NATSSource struct {
+
+	// Subject is the subject the tweets are published on, which may have
+	// wildcards. Stream is the stream they are stored in; if it is empty,
+	// it is looked up from the subject.
+	Subject string
+	Stream  string
+
+	// Group names the durable consumer.
+	Group string
+
+	// AckWait is how long NATS waits for a tweet to be acknowledged, or
+	// to hear that it is still in progress, before delivering it again.
+	AckWait time.Duration
+
+	nc  *nats.Conn
+	js  jetstream.JetStream
+	seq uint64
+}
+
+// OpenNATSSource connects to the NATS server at url. If the server is down
+// the connection keeps being retried.
+func OpenNATSSource(url, subject, stream, group string) (*NATSSource, error) {
+	nc, err := nats.Connect(url,
+		nats.Name("sentiment"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2*time.Second),
+	)
+	if err != nil {
+		return nil, err
+	}
+	js, err := jetstream.New(nc)
+	if err != nil {
+		nc.Close()
+		return nil, err
+	}
+	return &NATSSource{
+		Subject: subject,
+		Stream:  stream,
+		Group:   group,
+		AckWait: natsAckWait,
+		nc:      nc,
+		js:      js,
+	}, nil
+}
+
+// Close sends the acknowledgements still buffered and closes the
+// connection. Tweets acknowledged after Close are delivered again.
+func (s *NATSSource) Close() error {
+	err := s.nc.FlushTimeout(natsAckTimeout)
+	s.nc.Close()
+	return err
+}
+
+// Run consumes the tweets until the context is done, reconnecting and
+// carrying on where the group left off if NATS goes away. The connection
+// is kept open after Run returns, so the last tweets can still be
+// acknowledged.
+func (s *NATSSource) Run(ctx context.Context, live *LiveFilter, tweets chan<- Tweet) error {
+	var delay time.Duration
+	for {
+		err := s.consume(ctx, live, tweets)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Wait a little longer each time before trying again.
+		if delay = 2 * delay; delay == 0 {
+			delay = 500 * time.Millisecond
+		} else if delay > natsRetryMax {
+			delay = natsRetryMax
+		}
+		fmt.Printf("Error consuming tweets from NATS: %v (retrying in %v)\n", err, delay)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+	}
+}
+
+// consume joins the group's consumer and sends its tweets on the tweets
+// channel until the context is done or consuming fails.
+func (s *NATSSource) consume(ctx context.Context, live *LiveFilter, tweets chan<- Tweet) error {
+	consumer, err := s.consumer(ctx)
+	if err != nil {
+		return err
+	}
+	msgs, err := consumer.Messages()
+	if err != nil {
+		return err
+	}
+	defer msgs.Stop()
+
+	for {
+		msg, err := msgs.Next(jetstream.NextContext(ctx))
+		if err != nil {
+			return err
+		}
+		tweetsReceived.Inc()
+
+		// Give up on messages that aren't tweets, rather than having them
+		// delivered again and again.
+		var t Tweet
+		if err := json.Unmarshal(msg.Data(), &t); err != nil {
+			tweetsSkipped.WithLabelValues("invalid").Inc()
+			fmt.Printf("Error decoding tweet from %s: %v\n", msg.Subject(), err)
+			if err := msg.Term(); err != nil {
+				fmt.Println("Error acknowledging tweet:", err)
+			}
+			continue
+		}
+		t.ack = msg.Ack
+		t.progress = s.progress(msg)
+
+		filter, _ := live.Get()
+		if !filter.Match(&t) {
+			t.Done()
+			continue
+		}
+		s.seq++
+		t.Seq = s.seq
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case tweets <- t:
+		}
+	}
+}
+
+// progress returns a function telling NATS the message is still in
+// progress. It only does so once a quarter of AckWait has passed since the
+// message was delivered or last reported, so it can be called often.
+func (s *NATSSource) progress(msg jetstream.Msg) func() error {
+	var last atomic.Int64
+	last.Store(time.Now().UnixNano())
+	return func() error {
+		now := time.Now().UnixNano()
+		prev := last.Load()
+		if time.Duration(now-prev) < s.AckWait/4 || !last.CompareAndSwap(prev, now) {
+			return nil
+		}
+		return msg.InProgress()
+	}
+}
+
+// consumer creates the group's durable consumer, or updates it if it
+// exists.
+func (s *NATSSource) consumer(ctx context.Context) (jetstream.Consumer, error) {
+	ctx, cancel := context.WithTimeout(ctx, natsAckTimeout)
+	defer cancel()
+
+	stream := s.Stream
+	if stream == "" {
+		var err error
+		if stream, err = s.js.StreamNameBySubject(ctx, s.Subject); err != nil {
+			if errors.Is(err, jetstream.ErrStreamNotFound) {
+				return nil, fmt.Errorf("no stream stores %s", s.Subject)
+			}
+			return nil, err
+		}
+	}
+	return s.js.CreateOrUpdateConsumer(ctx, stream, jetstream.ConsumerConfig{
+		Durable:       s.Group,
+		FilterSubject: s.Subject,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       s.AckWait,
+		MaxAckPending: natsMaxAckPending,
+	})
+}
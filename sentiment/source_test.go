@@ -0,0 +1,98 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+func TestNATSSource(t *testing.T) {
+	srv := runJetStream(t, t.TempDir(), -1)
+	nc, err := nats.Connect(srv.ClientURL())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer nc.Close()
+	js, err := jetstream.New(nc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if _, err := js.CreateStream(ctx, jetstream.StreamConfig{Name: "TWEETS", Subjects: []string{"tweets.>"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the tweet about Trump is sent on; the others are acknowledged
+	// straight away.
+	for _, data := range []string{
+		`not a tweet`,
+		`{"id_str": "1", "text": "Russia"}`,
+		`{"id_str": "2", "text": "Trump rally"}`,
+	} {
+		if _, err := js.Publish(ctx, "tweets.en", []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	const ackWait = 500 * time.Millisecond
+	s, err := OpenNATSSource(srv.ClientURL(), "tweets.>", "TWEETS", "analyzers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.AckWait = ackWait
+	tweets := make(chan Tweet)
+	go s.Run(ctx, NewLiveFilter(Filter{Track: []string{"Trump"}}), tweets)
+
+	receive := func(within time.Duration) (Tweet, bool) {
+		select {
+		case tw := <-tweets:
+			return tw, true
+		case <-time.After(within):
+			return Tweet{}, false
+		}
+	}
+	tw, ok := receive(10 * time.Second)
+	if !ok {
+		t.Fatal("got no tweet")
+	}
+	if tw.ID != "2" || !reflect.DeepEqual(tw.Terms, []string{"Trump"}) {
+		t.Fatalf("got tweet %q about %q, want 2 about Trump", tw.ID, tw.Terms)
+	}
+
+	// While it is in progress, e.g., parked, it isn't delivered again.
+	for end := time.Now().Add(3 * ackWait); time.Now().Before(end); {
+		tw.Progress()
+		if again, ok := receive(50 * time.Millisecond); ok {
+			t.Fatalf("got tweet %q again while it was in progress", again.ID)
+		}
+	}
+
+	// Once we stop working on it, it is delivered again.
+	again, ok := receive(4 * ackWait)
+	if !ok || again.ID != "2" {
+		t.Fatalf("got tweet %q, %v, want 2 delivered again", again.ID, ok)
+	}
+
+	// And once it is done, it isn't.
+	again.Done()
+	if tw, ok := receive(3 * ackWait); ok {
+		t.Fatalf("got tweet %q after it was done", tw.ID)
+	}
+	consumer, err := js.Consumer(ctx, "TWEETS", "analyzers")
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := consumer.Info(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.NumAckPending != 0 || info.NumPending != 0 {
+		t.Errorf("got %d tweets waiting for an ack and %d to deliver, want none", info.NumAckPending, info.NumPending)
+	}
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// Scored tweets are written to SQLite in batches of up to sqliteBatchSize,
+// at least every sqliteFlushInterval. If a batch can't be written it is
+// tried again every interval, with at most sqliteMaxPending tweets kept
+// waiting.
+const (
+	sqliteBatchSize     = 500
+	sqliteFlushInterval = time.Second
+	sqliteMaxPending    = 10 * sqliteBatchSize
+)
+
+// sqliteSchema creates the tables for the scored tweets. Each tweet has a
+// row in tweets, and a row in tweet_terms for every tracked term it
+// mentions with the sentiment towards that term. Times are Unix seconds.
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS tweets (
+	id          TEXT PRIMARY KEY,
+	created_at  INTEGER NOT NULL,
+	user_id     TEXT NOT NULL,
+	screen_name TEXT NOT NULL,
+	lang        TEXT NOT NULL,
+	text        TEXT NOT NULL,
+	sentiment   REAL NOT NULL,
+	label       TEXT NOT NULL,
+	analyzer    TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS tweets_created_at ON tweets (created_at);
+CREATE INDEX IF NOT EXISTS tweets_user_id ON tweets (user_id, created_at);
+
+CREATE TABLE IF NOT EXISTS tweet_terms (
+	tweet_id   TEXT NOT NULL REFERENCES tweets (id),
+	term       TEXT NOT NULL,
+	created_at INTEGER NOT NULL,
+	sentiment  REAL NOT NULL,
+	label      TEXT NOT NULL,
+	PRIMARY KEY (tweet_id, term)
+);
+CREATE INDEX IF NOT EXISTS tweet_terms_term ON tweet_terms (term, created_at);
+CREATE INDEX IF NOT EXISTS tweet_terms_created_at ON tweet_terms (created_at);
+`
+
+// openSQLite opens the SQLite database at path, creating the tables if
+// needed.
+func openSQLite(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating tables: %v", err)
+	}
+	return db, nil
+}
+
+// SQLiteSink writes scored tweets to SQLite, so they can be queried later.
+// Tweets are queued and inserted in batches by a background goroutine.
+type SQLiteSink struct {
+	db     *sql.DB
+	tweets chan ScoredTweet
+	done   chan struct{}
+
+	// Tweets without an ID are given one made from when the sink was
+	// opened and a count of such tweets.
+	opened  time.Time
+	localID uint64
+}
+
+// OpenSQLiteSink opens the SQLite database at path and starts writing to
+// it.
+func OpenSQLiteSink(path string) (*SQLiteSink, error) {
+	db, err := openSQLite(path)
+	if err != nil {
+		return nil, err
+	}
+	s := &SQLiteSink{
+		db:     db,
+		tweets: make(chan ScoredTweet, 2*sqliteBatchSize),
+		done:   make(chan struct{}),
+		opened: time.Now(),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues a scored tweet to be written.
+func (s *SQLiteSink) Write(t ScoredTweet) error {
+	s.tweets <- t
+	return nil
+}
+
+// Close writes the queued tweets and closes the database.
+func (s *SQLiteSink) Close() error {
+	close(s.tweets)
+	<-s.done
+	return s.db.Close()
+}
+
+// run inserts the queued tweets in batches until the sink is closed. A
+// batch that fails, e.g., because the database is locked or the disk is
+// full, is kept and tried again on the next tick, growing as tweets
+// arrive until the oldest have to be dropped.
+func (s *SQLiteSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(sqliteFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]ScoredTweet, 0, sqliteBatchSize)
+	failing := false
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := s.insert(batch); err != nil {
+			fmt.Println("Error writing tweets to SQLite:", err)
+			sqliteFailures.Inc()
+			failing = true
+			return
+		}
+		failing = false
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case t, ok := <-s.tweets:
+			if !ok {
+				flush()
+				if failing {
+					sqliteDropped.Add(float64(len(batch)))
+				}
+				return
+			}
+			batch = append(batch, t)
+			if over := len(batch) - sqliteMaxPending; over > 0 {
+				batch = append(batch[:0], batch[over:]...)
+				sqliteDropped.Add(float64(over))
+			}
+			if !failing && len(batch) >= sqliteBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// insert writes a batch of tweets in a single transaction. Tweets already
+// in the database, e.g., sent again after a reconnection, are skipped, so
+// a failed batch can safely be inserted again.
+func (s *SQLiteSink) insert(batch []ScoredTweet) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	insertTweet, err := tx.Prepare(`INSERT OR IGNORE INTO tweets
+		(id, created_at, user_id, screen_name, lang, text, sentiment, label, analyzer)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertTweet.Close()
+	insertTerm, err := tx.Prepare(`INSERT OR IGNORE INTO tweet_terms
+		(tweet_id, term, created_at, sentiment, label)
+		VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insertTerm.Close()
+
+	now := time.Now()
+	for _, t := range batch {
+		created, ok := t.Time()
+		if !ok {
+			created = now
+		}
+		id := t.ID
+		if id == "" {
+			s.localID++
+			id = fmt.Sprintf("local-%d-%d", s.opened.UnixNano(), s.localID)
+		}
+		if _, err := insertTweet.Exec(id, created.Unix(), t.User.ID, t.User.ScreenName,
+			t.Lang, t.Text, t.Sentiment, t.Label, t.Analyzer); err != nil {
+			return err
+		}
+		for _, a := range t.Aspects {
+			if a.Kind != aspectTerm {
+				continue
+			}
+			if _, err := insertTerm.Exec(id, a.Name, created.Unix(), a.Sentiment, Label(a.Sentiment)); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"sort"
+	"sync"
+)
+
+// Breakdown is the average sentiment and the counts of positive, negative
+// and neutral tweets for some set of tweets.
+type Breakdown struct {
+	SentimentAverage float64        `json:"sentiment_average"`
+	Counts           map[string]int `json:"counts"`
+
+	// Weight is the total weight of the tweets in the average. Tweets that
+	// are down-weighted (e.g., suspected bots) count for less than one.
+	Weight float64 `json:"weight"`
+
+	// WeightedAverage is the average sentiment with each tweet's weight
+	// also multiplied by the influence of its author, and InfluenceWeight
+	// the total of those weights.
+	WeightedAverage float64 `json:"weighted_average"`
+	InfluenceWeight float64 `json:"influence_weight"`
+}
+
+// NewBreakdown creates a new, empty Breakdown.
+func NewBreakdown() *Breakdown {
+	return &Breakdown{
+		SentimentAverage: 0.0,
+		Counts: map[string]int{
+			"positive": 0,
+			"negative": 0,
+			"neutral":  0,
+			"total":    0,
+		},
+	}
+}
+
+// Add adds the sentiment of a tweet, with the given weight in the average
+// and that weight times the influence of its author in the weighted
+// average.
+func (b *Breakdown) Add(sentiment, weight, influence float64) {
+	b.SentimentAverage, b.Weight = addWeighted(b.SentimentAverage, b.Weight, sentiment, weight)
+	b.WeightedAverage, b.InfluenceWeight = addWeighted(b.WeightedAverage, b.InfluenceWeight, sentiment, weight*influence)
+	b.Counts[Label(sentiment)]++
+	b.Counts["total"]++
+}
+
+// Merge adds the tweets counted in another breakdown.
+func (b *Breakdown) Merge(o *Breakdown) {
+	b.SentimentAverage, b.Weight = addWeighted(b.SentimentAverage, b.weight(), o.SentimentAverage, o.weight())
+	b.WeightedAverage, b.InfluenceWeight = addWeighted(b.WeightedAverage, b.InfluenceWeight, o.WeightedAverage, o.InfluenceWeight)
+	for k, v := range o.Counts {
+		b.Counts[k] += v
+	}
+}
+
+// weight returns the total weight of the tweets. Stats saved before tweets
+// were weighted have no weight, and every tweet counted fully.
+func (b *Breakdown) weight() float64 {
+	if b.Weight == 0 {
+		return float64(b.Counts["total"])
+	}
+	return b.Weight
+}
+
+// addWeighted adds a value with weight w to an average with total weight
+// total, returning the new average and total weight.
+func addWeighted(average, total, value, w float64) (float64, float64) {
+	if total+w > 0 {
+		average = (average*total + value*w) / (total + w)
+	}
+	return average, total + w
+}
+
+// Stats stores aggregated stats about
+// tweets collected over time
+type Stats struct {
+	Breakdown
+
+	// Languages breaks the stats down by the language of the tweets.
+	Languages map[string]*Breakdown
+
+	// Terms breaks the stats down by tracked term, using the sentiment
+	// towards each term rather than that of the whole tweet.
+	Terms map[string]*Breakdown
+
+	// Accounts breaks the stats down into tweets from suspected bots and
+	// from humans, each counted fully. It is empty if bot detection is off.
+	Accounts map[string]*Breakdown
+
+	// Users are the profiles of the most active users.
+	Users *UserProfiles
+
+	// Dropped counts the tweets that weren't analyzed, by the reason.
+	Dropped map[string]int
+
+	// Emoji counts the emoji used in tweets about each tracked term.
+	Emoji map[string]map[string]int
+
+	Mux sync.Mutex
+}
+
+// NewStats creates a new, empty Stats.
+func NewStats() *Stats {
+	s := &Stats{}
+	s.init()
+	return s
+}
+
+// init sets up empty stats in place.
+func (s *Stats) init() {
+	s.Breakdown = *NewBreakdown()
+	s.Languages = make(map[string]*Breakdown)
+	s.Terms = make(map[string]*Breakdown)
+	s.Accounts = make(map[string]*Breakdown)
+	s.Dropped = make(map[string]int)
+	s.Users = NewUserProfiles(maxUserProfiles)
+	s.Emoji = make(map[string]map[string]int)
+}
+
+// Label returns the label ("positive", "negative" or "neutral") for a
+// sentiment.
+func Label(sentiment float64) string {
+	switch {
+	case sentiment > 0.80:
+		return "positive"
+	case sentiment < 0.50:
+		return "negative"
+	default:
+		return "neutral"
+	}
+}
+
+// Record adds a scored tweet to the stats.
+func (s *Stats) Record(t ScoredTweet) {
+	s.Mux.Lock()
+	defer s.Mux.Unlock()
+
+	s.Add(t.Sentiment, t.Weight, t.Influence)
+	if t.Lang != "" {
+		breakdownFor(s.Languages, t.Lang).Add(t.Sentiment, t.Weight, t.Influence)
+	}
+	for _, a := range t.Aspects {
+		if a.Kind == aspectTerm {
+			breakdownFor(s.Terms, a.Name).Add(a.Sentiment, t.Weight, t.Influence)
+		}
+	}
+	if t.User.ID != "" {
+		s.Users.Add(t)
+	}
+	if t.Account != "" {
+		breakdownFor(s.Accounts, t.Account).Add(t.Sentiment, 1, t.Influence)
+	}
+	for _, term := range t.Terms {
+		for _, e := range t.Emoji.Emoji {
+			countsFor(s.Emoji, term)[e]++
+		}
+	}
+}
+
+// Merge adds the tweets counted in other stats. Only o is locked, so s
+// must not be in use by anyone else.
+func (s *Stats) Merge(o *Stats) {
+	o.Mux.Lock()
+	defer o.Mux.Unlock()
+
+	s.Breakdown.Merge(&o.Breakdown)
+	for lang, b := range o.Languages {
+		breakdownFor(s.Languages, lang).Merge(b)
+	}
+	for term, b := range o.Terms {
+		breakdownFor(s.Terms, term).Merge(b)
+	}
+	for kind, b := range o.Accounts {
+		breakdownFor(s.Accounts, kind).Merge(b)
+	}
+	mergeCounts(s.Dropped, o.Dropped)
+	s.Users.Merge(o.Users)
+	for term, counts := range o.Emoji {
+		mergeCounts(countsFor(s.Emoji, term), counts)
+	}
+}
+
+// RecordDropped counts a tweet that wasn't analyzed.
+func (s *Stats) RecordDropped(reason string) {
+	s.Mux.Lock()
+	defer s.Mux.Unlock()
+
+	s.Dropped[reason]++
+}
+
+// Track starts empty stats for any of the terms that don't have them yet,
+// so newly tracked terms show up before their first tweet. The stats for
+// terms that are no longer tracked are kept.
+func (s *Stats) Track(terms []string) {
+	s.Mux.Lock()
+	defer s.Mux.Unlock()
+
+	for _, term := range terms {
+		breakdownFor(s.Terms, term)
+	}
+}
+
+// TopEmoji returns the n emoji used most in tweets about the term.
+func (s *Stats) TopEmoji(term string, n int) []EmojiCount {
+	s.Mux.Lock()
+	defer s.Mux.Unlock()
+	return topEmoji(s.Emoji[term], n)
+}
+
+// breakdownFor returns the breakdown for key, adding it if needed.
+func breakdownFor(m map[string]*Breakdown, key string) *Breakdown {
+	b, ok := m[key]
+	if !ok {
+		b = NewBreakdown()
+		m[key] = b
+	}
+	return b
+}
+
+// countsFor returns the counts for key, adding them if needed.
+func countsFor(m map[string]map[string]int, key string) map[string]int {
+	c, ok := m[key]
+	if !ok {
+		c = make(map[string]int)
+		m[key] = c
+	}
+	return c
+}
+
+// mergeCounts adds the counts in o to c.
+func mergeCounts(c, o map[string]int) {
+	for k, v := range o {
+		c[k] += v
+	}
+}
+
+// sortedKeys returns the keys of a breakdown map in order.
+func sortedKeys(m map[string]*Breakdown) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// StatsRecorder records analyzed tweets. Both Stats and the shards of a
+// ShardedStats are StatsRecorders.
+type StatsRecorder interface {
+	Record(t ScoredTweet)
+	RecordDropped(reason string)
+}
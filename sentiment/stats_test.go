@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// benchWorkers are the numbers of workers the stats are benchmarked with.
+var benchWorkers = []int{1, 8, 64}
+
+// BenchmarkStatsShared is the baseline for BenchmarkStatsSharded, with
+// every worker recording into one Stats behind its mutex.
+func BenchmarkStatsShared(b *testing.B) {
+	for _, workers := range benchWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := NewStats()
+			runWorkers(b.N, workers, func(int) StatsRecorder { return s })
+		})
+	}
+}
+
+func BenchmarkStatsSharded(b *testing.B) {
+	for _, workers := range benchWorkers {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			s := NewShardedStats(NewStats(), workers)
+			runWorkers(b.N, workers, func(w int) StatsRecorder { return s.Shard(w) })
+			s.Snapshot()
+		})
+	}
+}
+
+// runWorkers records n tweets spread over the given number of workers,
+// each using the recorder returned by recorder.
+func runWorkers(n, workers int, recorder func(w int) StatsRecorder) {
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			r := recorder(w)
+			for i := w; i < n; i += workers {
+				sentiment := float64(i%100) / 100
+				r.Record(ScoredTweet{
+					Tweet:     Tweet{Lang: "en"},
+					Sentiment: sentiment,
+					Label:     Label(sentiment),
+					Weight:    1,
+					Influence: 1,
+				})
+			}
+		}(w)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// runStream streams tweets matching the configured filter, analyzes them
+// and reports the stats until the configured duration is up or the
+// process is interrupted.
+func runStream(cfg Config) error {
+	if err := cfg.ValidateStream(); err != nil {
+		return err
+	}
+
+	// Create the source of the tweets: Twitter, or NATS if configured.
+	r, err := NewTweetSource(cfg)
+	if err != nil {
+		return fmt.Errorf("opening tweet source: %v", err)
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer func() {
+			if err := c.Close(); err != nil {
+				fmt.Println("Error closing tweet source:", err)
+			}
+		}()
+	}
+
+	// Create the analyzers, protected by timeouts, retries and circuit
+	// breakers, and the rest of the pipeline.
+	p, err := NewPipeline(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Load the stats saved by previous runs, including the windowed stats.
+	windows := NewWindowedStats(windowBucket, windowBuckets)
+	savedStats, err := LoadStats(cfg.StatsPath, windows)
+	if err != nil {
+		return fmt.Errorf("loading stats: %v", err)
+	}
+
+	// Give each worker its own shard of the stats.
+	myStats := NewShardedStats(savedStats, cfg.Workers)
+
+	// Open the dead-letter file for tweets that fail analysis.
+	deadLetters, err := OpenDeadLetterSink(cfg.DeadLetterPath)
+	if err != nil {
+		return fmt.Errorf("opening dead-letter file: %v", err)
+	}
+	defer deadLetters.Close()
+	p.DeadLetters = deadLetters
+
+	// Open the sinks the scored tweets are saved to.
+	sinks, err := OpenSinks(cfg)
+	if err != nil {
+		return err
+	}
+
+	// Run until we are interrupted or stopped or, if there is one, the
+	// duration is up.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if cfg.Duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, cfg.Duration)
+		defer cancel()
+	}
+
+	// The tracked terms can be changed while we run. Start stats for each
+	// term as it is added.
+	filter := NewLiveFilter(cfg.Filter())
+	go func() {
+		for {
+			f, changed := filter.Get()
+			myStats.Track(f.Track)
+			select {
+			case <-ctx.Done():
+				return
+			case <-changed:
+			}
+		}
+	}()
+
+	// Buffer the incoming tweets a little, so a burst doesn't stall the
+	// stream while the workers catch up.
+	tweets := make(chan Tweet, 100)
+	p.Parked = make(chan Tweet, 1000)
+
+	// Scored tweets come out of the workers in whatever order they finish,
+	// which is the fastest. Ordered gets them in arrival order instead.
+	results := make(chan ScoredTweet, 100)
+	p.Results = results
+	var scored <-chan ScoredTweet = results
+	if cfg.Ordered {
+		p.Ordered = true
+		scored = Reorder(results, 1000, 5*time.Second)
+	}
+
+	fmt.Println("Start tweet workers...")
+	var working sync.WaitGroup
+	for w := 0; w < cfg.Workers; w++ {
+		working.Add(1)
+		go func(shard StatsRecorder) {
+			defer working.Done()
+			p.tweetWorker(ctx, shard, tweets)
+		}(myStats.Shard(w))
+	}
+
+	fmt.Println("Start a goroutine to keep the recent scored tweets, windowed stats and trends...")
+	recent := NewRecentTweets(100)
+	trends := NewTrends(cfg.TrendWindow, cfg.TrendBaseline)
+	feed := NewFeed()
+	collected := make(chan struct{})
+	go func() {
+		defer close(collected)
+		for t := range scored {
+			now := time.Now()
+			recent.Add(t)
+			windows.Record(t, now)
+			trends.Record(t, now)
+			feed.PublishTweet(t)
+			for _, s := range sinks {
+				if err := s.Write(t); err != nil {
+					fmt.Println("Error writing scored tweet:", err)
+				}
+			}
+		}
+	}()
+
+	if cfg.HTTPAddr != "" {
+		metrics := NewMetricsRegistry(myStats, map[string]func() int{
+			"tweets":  func() int { return len(tweets) },
+			"parked":  func() int { return len(p.Parked) },
+			"results": func() int { return len(results) },
+		})
+		fmt.Println("Start the HTTP API on", cfg.HTTPAddr+"...")
+		api := &API{
+			Stats:   myStats,
+			Windows: windows,
+			Recent:  recent,
+			Trends:  trends,
+			Feed:    feed,
+			Metrics: promhttp.HandlerFor(metrics, promhttp.HandlerOpts{}),
+
+			Filter:     filter,
+			AdminToken: cfg.AdminToken,
+		}
+		go func() {
+			if err := serve(ctx, cfg.HTTPAddr, api.Handler()); err != nil {
+				fmt.Println("Error serving the HTTP API:", err)
+			}
+		}()
+	}
+
+	fmt.Println("Start a goroutine to retry parked tweets...")
+	working.Add(1)
+	go func() {
+		defer working.Done()
+		drainParked(ctx, p.Router, p.Parked, tweets, deadLetters)
+	}()
+
+	fmt.Println("Start another goroutine to collect tweets...")
+	go func() {
+		if err := r.Run(ctx, filter, tweets); err != nil {
+			fmt.Println("Error streaming tweets:", err)
+		}
+	}()
+
+	// Check on our stats, and save them every so often so a crash doesn't
+	// lose them.
+	ticker := time.NewTicker(cfg.ReportInterval)
+	defer ticker.Stop()
+	var checkpoints <-chan time.Time
+	if cfg.CheckpointInterval > 0 {
+		checkpoint := time.NewTicker(cfg.CheckpointInterval)
+		defer checkpoint.Stop()
+		checkpoints = checkpoint.C
+	}
+	var previous *Stats
+	for {
+		select {
+		case <-ticker.C:
+		case <-checkpoints:
+			if err := myStats.Snapshot().Save(cfg.StatsPath, windows); err != nil {
+				fmt.Println("Error saving stats:", err)
+			}
+			continue
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		fmt.Println("")
+		snapshot := myStats.Snapshot()
+		printStats(snapshot, cfg)
+		feed.PublishStats(statsDelta(previous, snapshot, time.Now()))
+		previous = snapshot
+		for _, s := range sinks {
+			if s, ok := s.(StatsSink); ok {
+				if err := s.WriteStats(snapshot); err != nil {
+					fmt.Println("Error writing stats to sink:", err)
+				}
+			}
+		}
+		printTrends(trends.Trending(time.Now(), 10))
+		for _, latest := range recent.Latest(1) {
+			fmt.Printf("Latest tweet (#%d, %s): %s\n", latest.Seq, latest.Label, latest.Text)
+		}
+	}
+
+	// Wait for the workers and the parked tweet retries to stop and the
+	// scored tweets to be collected, then dead-letter the tweets still
+	// parked.
+	working.Wait()
+	close(results)
+	<-collected
+	for len(p.Parked) > 0 {
+		select {
+		case t := <-p.Parked:
+			deadLetterParked(deadLetters, t)
+		default:
+		}
+	}
+
+	// Write the last of the scored tweets to the sinks, and save the stats
+	// for next time.
+	var errs []error
+	for _, s := range sinks {
+		if err := s.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing sink: %v", err))
+		}
+	}
+	if err := myStats.Snapshot().Save(cfg.StatsPath, windows); err != nil {
+		errs = append(errs, fmt.Errorf("saving stats: %v", err))
+	}
+	return errors.Join(errs...)
+}
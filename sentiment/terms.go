@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// termsUsage describes the terms command's arguments.
+const termsUsage = "usage: sentiment terms [flags] list | add term... | remove term..."
+
+// runTerms lists, adds or removes the terms tracked by a running stream,
+// through the admin API at the configured -http address.
+func runTerms(cfg Config, args []string) error {
+	if cfg.HTTPAddr == "" || cfg.AdminToken == "" {
+		return errors.New("-http and -admin-token must match those of the running stream")
+	}
+	if len(args) == 0 {
+		return errors.New(termsUsage)
+	}
+
+	// Work out the request.
+	method, body := http.MethodGet, []byte(nil)
+	switch cmd, terms := args[0], args[1:]; cmd {
+	case "list":
+		if len(terms) > 0 {
+			return errors.New(termsUsage)
+		}
+	case "add", "remove":
+		if len(terms) == 0 {
+			return fmt.Errorf("no terms to %s\n%s", cmd, termsUsage)
+		}
+		var u TermsUpdate
+		if cmd == "add" {
+			u.Add = terms
+		} else {
+			u.Remove = terms
+		}
+		var err error
+		if body, err = json.Marshal(u); err != nil {
+			return err
+		}
+		method = http.MethodPost
+	default:
+		return fmt.Errorf("unknown terms command %q\n%s", cmd, termsUsage)
+	}
+
+	// Send it to the running stream.
+	req, err := http.NewRequest(method, apiURL(cfg.HTTPAddr)+"/api/admin/terms", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+cfg.AdminToken)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := NewClient(10*time.Second, 1).Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var tracked TrackedTerms
+	if err := json.NewDecoder(resp.Body).Decode(&tracked); err != nil {
+		return fmt.Errorf("decoding response: %v", err)
+	}
+	fmt.Println("Tracking:", strings.Join(tracked.Terms, ", "))
+	return nil
+}
+
+// apiURL turns a listen address like :8000 into the URL of the API.
+func apiURL(addr string) string {
+	if strings.HasPrefix(addr, "http://") || strings.HasPrefix(addr, "https://") {
+		return strings.TrimSuffix(addr, "/")
+	}
+	if strings.HasPrefix(addr, ":") {
+		addr = "localhost" + addr
+	}
+	return "http://" + addr
+}
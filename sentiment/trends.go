@@ -0,0 +1,274 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of trending items.
+const (
+	trendHashtag = "hashtag"
+	trendKeyword = "keyword"
+	trendNGram   = "ngram"
+)
+
+const (
+
+	// trendBuckets is how many buckets the window is split into. The
+	// window slides by one bucket at a time.
+	trendBuckets = 10
+
+	// maxBucketItems is how many items a bucket keeps once it is closed.
+	// Only the most frequent are kept, which are the ones that can trend.
+	maxBucketItems = 2000
+
+	// minTrendCount is how many tweets in the window an item needs before
+	// it can trend.
+	minTrendCount = 3
+
+	// trendPrior is added to the expected count, so items the baseline has
+	// never seen need a few tweets to trend rather than one.
+	trendPrior = 2.0
+)
+
+// Trend is how often an item (a hashtag, keyword or n-gram) came up in the
+// current window compared to the baseline, and the sentiment of the tweets
+// it came up in.
+type Trend struct {
+	Kind string `json:"kind"`
+	Text string `json:"text"`
+
+	// Count is the number of tweets in the window with the item, Expected
+	// how many the baseline predicts, and Ratio how many times more than
+	// expected it came up.
+	Count    int     `json:"count"`
+	Expected float64 `json:"expected"`
+	Ratio    float64 `json:"ratio"`
+
+	SentimentAverage float64 `json:"sentiment_average"`
+}
+
+// trendKey identifies an item.
+type trendKey struct {
+	kind, text string
+}
+
+// trendCount is the number of tweets with an item and their total
+// sentiment.
+type trendCount struct {
+	count     int
+	sentiment float64
+}
+
+// Trends tracks hashtags, MachineBox keywords and n-grams in a sliding
+// window, and compares their frequency with a baseline: an exponential
+// moving average of their counts over a longer period. It is safe for
+// concurrent use.
+type Trends struct {
+	window   time.Duration
+	bucket   time.Duration
+	alpha    float64
+	mux      sync.Mutex
+	started  time.Time
+	current  time.Time
+	buckets  []map[trendKey]*trendCount
+	next     int
+	baseline map[trendKey]float64
+}
+
+// NewTrends creates a Trends with the given window and baseline period.
+func NewTrends(window, baseline time.Duration) *Trends {
+	t := &Trends{
+		window:   window,
+		bucket:   window / trendBuckets,
+		alpha:    float64(window/trendBuckets) / float64(baseline),
+		buckets:  make([]map[trendKey]*trendCount, trendBuckets),
+		baseline: make(map[trendKey]float64),
+	}
+	for i := range t.buckets {
+		t.buckets[i] = make(map[trendKey]*trendCount)
+	}
+	return t
+}
+
+// Record adds the items in a scored tweet received at now.
+func (t *Trends) Record(s ScoredTweet, now time.Time) {
+	items := trendItems(s)
+
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.advance(now)
+	b := t.buckets[t.next]
+	for _, key := range items {
+		c, ok := b[key]
+		if !ok {
+			c = &trendCount{}
+			b[key] = c
+		}
+		c.count++
+		c.sentiment += s.Sentiment
+	}
+}
+
+// advance moves the window on to now, closing the buckets that have ended
+// and folding them into the baseline.
+func (t *Trends) advance(now time.Time) {
+	if t.started.IsZero() {
+		t.started, t.current = now, now
+		return
+	}
+	for n := 0; now.Sub(t.current) >= t.bucket; n++ {
+
+		// After a long gap, just empty every bucket.
+		if n > trendBuckets {
+			t.current = now
+			break
+		}
+		t.close(t.buckets[t.next])
+		t.next = (t.next + 1) % trendBuckets
+		t.buckets[t.next] = make(map[trendKey]*trendCount)
+		t.current = t.current.Add(t.bucket)
+	}
+}
+
+// close prunes a bucket to its most frequent items and folds it into the
+// baseline.
+func (t *Trends) close(b map[trendKey]*trendCount) {
+	if len(b) > maxBucketItems {
+		counts := make([]int, 0, len(b))
+		for _, c := range b {
+			counts = append(counts, c.count)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(counts)))
+		min := counts[maxBucketItems-1]
+		for key, c := range b {
+			if c.count < min {
+				delete(b, key)
+			}
+		}
+	}
+
+	// Forget items that have all but stopped coming up.
+	for key, v := range t.baseline {
+		v *= 1 - t.alpha
+		if _, ok := b[key]; !ok && v*trendBuckets < 0.01 {
+			delete(t.baseline, key)
+			continue
+		}
+		t.baseline[key] = v
+	}
+	for key, c := range b {
+		t.baseline[key] += t.alpha * float64(c.count)
+	}
+}
+
+// Trending returns up to n items that came up more than usual in the
+// window up to now, the most unusual first. Nothing trends until the
+// first window is full.
+func (t *Trends) Trending(now time.Time, n int) []Trend {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.advance(now)
+	if t.started.IsZero() || now.Sub(t.started) < t.window {
+		return nil
+	}
+
+	window := make(map[trendKey]*trendCount)
+	for _, b := range t.buckets {
+		for key, c := range b {
+			w, ok := window[key]
+			if !ok {
+				w = &trendCount{}
+				window[key] = w
+			}
+			w.count += c.count
+			w.sentiment += c.sentiment
+		}
+	}
+
+	var trends []Trend
+	for key, c := range window {
+		if c.count < minTrendCount {
+			continue
+		}
+		expected := t.baseline[key] * trendBuckets
+		ratio := float64(c.count) / (expected + trendPrior)
+		if ratio <= 1 {
+			continue
+		}
+		trends = append(trends, Trend{
+			Kind:             key.kind,
+			Text:             key.text,
+			Count:            c.count,
+			Expected:         expected,
+			Ratio:            ratio,
+			SentimentAverage: c.sentiment / float64(c.count),
+		})
+	}
+	sort.Slice(trends, func(i, j int) bool {
+		if trends[i].Ratio != trends[j].Ratio {
+			return trends[i].Ratio > trends[j].Ratio
+		}
+		return trends[i].Text < trends[j].Text
+	})
+	if len(trends) > n {
+		trends = trends[:n]
+	}
+	return trends
+}
+
+// wordRe matches the words n-grams are made of.
+var wordRe = regexp.MustCompile(`[\p{L}\p{N}']+`)
+
+// stopWords are common English words that n-grams mustn't start or end
+// with, so that we get "climate change" rather than "the climate".
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "for": true, "from": true, "has": true,
+	"have": true, "he": true, "her": true, "his": true, "i": true, "in": true,
+	"is": true, "it": true, "its": true, "it's": true, "me": true, "my": true,
+	"not": true, "of": true, "on": true, "or": true, "our": true, "rt": true,
+	"she": true, "so": true, "that": true, "the": true, "their": true,
+	"they": true, "this": true, "to": true, "was": true, "we": true,
+	"were": true, "what": true, "who": true, "will": true, "with": true,
+	"you": true, "your": true,
+}
+
+// trendItems returns the distinct hashtags, keywords and n-grams (two and
+// three words long) of a scored tweet.
+func trendItems(s ScoredTweet) []trendKey {
+	seen := make(map[trendKey]bool)
+	var items []trendKey
+	add := func(kind, text string) {
+		key := trendKey{kind, strings.ToLower(text)}
+		if key.text != "" && !seen[key] {
+			seen[key] = true
+			items = append(items, key)
+		}
+	}
+
+	for _, m := range hashtagRe.FindAllStringSubmatch(s.Text, -1) {
+		add(trendHashtag, "#"+m[1])
+	}
+	if s.Analysis != nil {
+		for _, k := range s.Analysis.Keywords {
+			add(trendKeyword, k.Keyword)
+		}
+	}
+
+	words := wordRe.FindAllString(strings.ToLower(s.AnalyzedText), -1)
+	for n := 2; n <= 3; n++ {
+		for i := 0; i+n <= len(words); i++ {
+			if stopWords[words[i]] || stopWords[words[i+n-1]] {
+				continue
+			}
+			add(trendNGram, strings.Join(words[i:i+n], " "))
+		}
+	}
+	return items
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// trendRecord is a tweet recorded after a number of seconds.
+type trendRecord struct {
+	at   float64
+	text string
+}
+
+func TestTrends(t *testing.T) {
+
+	// The window is ten one-second buckets, and the baseline takes a few
+	// minutes to catch up with steady mentions.
+	tests := []struct {
+		name    string
+		records []trendRecord
+		at      float64
+		want    []string
+	}{
+		{
+			name:    "not enough history",
+			records: repeat(0, "#golang", 5),
+			at:      5,
+		},
+		{
+			name:    "burst",
+			records: append([]trendRecord{{0, "start"}}, repeat(10.2, "#golang", 5)...),
+			at:      10.5,
+			want:    []string{"#golang"},
+		},
+		{
+			name:    "too few mentions",
+			records: append([]trendRecord{{0, "start"}}, repeat(10.2, "#golang", 2)...),
+			at:      10.5,
+		},
+		{
+			name: "bigger bursts first",
+			records: append(append([]trendRecord{{0, "start"}},
+				repeat(10.2, "#golang", 4)...),
+				repeat(10.3, "#rust", 8)...),
+			at:   10.5,
+			want: []string{"#rust", "#golang"},
+		},
+		{
+			name:    "steady mentions don't trend",
+			records: steady("#golang", 5, 300),
+			at:      300.5,
+		},
+		{
+			name:    "burst over steady mentions",
+			records: append(steady("#golang", 1, 300), repeat(300.2, "#golang", 10)...),
+			at:      300.5,
+			want:    []string{"#golang"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Date(2017, 3, 1, 12, 0, 0, 0, time.UTC)
+			after := func(seconds float64) time.Time {
+				return start.Add(time.Duration(seconds * float64(time.Second)))
+			}
+
+			trends := NewTrends(10*time.Second, 100*time.Second)
+			for _, r := range tt.records {
+				var s ScoredTweet
+				s.Text = r.text
+				s.Sentiment = 0.5
+				trends.Record(s, after(r.at))
+			}
+
+			var got []string
+			for _, trend := range trends.Trending(after(tt.at), 10) {
+				got = append(got, trend.Text)
+				if trend.Kind != trendHashtag || trend.SentimentAverage != 0.5 {
+					t.Errorf("got trend %+v, want a hashtag with average 0.5", trend)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// repeat records the same text n times at once.
+func repeat(at float64, text string, n int) []trendRecord {
+	var records []trendRecord
+	for i := 0; i < n; i++ {
+		records = append(records, trendRecord{at, text})
+	}
+	return records
+}
+
+// steady records the text n times every second for the given number of
+// seconds.
+func steady(text string, n, seconds int) []trendRecord {
+	var records []trendRecord
+	for s := 0; s < seconds; s++ {
+		records = append(records, repeat(float64(s), text, n)...)
+	}
+	return records
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// maxUserProfiles is how many users UserProfiles keeps track of.
+const maxUserProfiles = 1000
+
+// minProfileTweets is how many tweets we need to have seen from a user
+// before ranking them by sentiment.
+const minProfileTweets = 3
+
+// UserProfile is the sentiment of a user's tweets.
+type UserProfile struct {
+	ID         string `json:"id"`
+	ScreenName string `json:"screen_name"`
+
+	// Count is an upper bound on how many tweets the user has posted, and
+	// Error how much it may be over by, as the user may have been evicted
+	// and come back (see UserProfiles).
+	Count int `json:"count"`
+	Error int `json:"error"`
+
+	// Breakdown is the sentiment of the tweets seen since the user was last
+	// added, and Terms breaks it down by tracked term.
+	Breakdown
+	Terms map[string]*Breakdown `json:"terms,omitempty"`
+
+	index int
+}
+
+// UserProfiles keeps the profiles of the most active users in bounded
+// memory, using the space-saving algorithm (Metwally et al., 2005): when it
+// is full, a new user replaces the user with the lowest count and takes
+// over that count, which becomes its error. The users with the highest
+// counts are always kept, however long the stream runs.
+type UserProfiles struct {
+	k     int
+	users map[string]*UserProfile
+	heap  profileHeap
+}
+
+// NewUserProfiles creates a UserProfiles keeping at most k users.
+func NewUserProfiles(k int) *UserProfiles {
+	return &UserProfiles{
+		k:     k,
+		users: make(map[string]*UserProfile),
+	}
+}
+
+// Add adds a scored tweet to its user's profile.
+func (u *UserProfiles) Add(t ScoredTweet) {
+	p, ok := u.users[t.User.ID]
+	if !ok {
+		p = u.admit(t.User.ID)
+	}
+	p.ScreenName = t.User.ScreenName
+	p.Count++
+	heap.Fix(&u.heap, p.index)
+
+	p.Breakdown.Add(t.Sentiment, t.Weight, t.Influence)
+	for _, term := range t.Terms {
+		breakdownFor(p.Terms, term).Add(t.Sentiment, t.Weight, t.Influence)
+	}
+}
+
+// admit adds a profile for a new user, evicting the user with the lowest
+// count if we are full.
+func (u *UserProfiles) admit(id string) *UserProfile {
+	p := &UserProfile{
+		ID:        id,
+		Breakdown: *NewBreakdown(),
+		Terms:     make(map[string]*Breakdown),
+	}
+	if len(u.heap) >= u.k {
+		min := heap.Pop(&u.heap).(*UserProfile)
+		delete(u.users, min.ID)
+		p.Count, p.Error = min.Count, min.Count
+	}
+	u.users[id] = p
+	heap.Push(&u.heap, p)
+	return p
+}
+
+// Merge adds the profiles in o, keeping the k users with the highest
+// counts. A user missing from one side may have been evicted from it, so
+// they are given that side's lowest count as both count and error.
+func (u *UserProfiles) Merge(o *UserProfiles) {
+	uMin, oMin := u.minCount(), o.minCount()
+	merged := make(map[string]*UserProfile, len(u.users)+len(o.users))
+	for id, p := range u.users {
+		p.Count += oMin
+		p.Error += oMin
+		merged[id] = p
+	}
+	for id, op := range o.users {
+		p, ok := merged[id]
+		if !ok {
+			p = &UserProfile{
+				ID:        id,
+				Count:     uMin,
+				Error:     uMin,
+				Breakdown: *NewBreakdown(),
+				Terms:     make(map[string]*Breakdown),
+			}
+			merged[id] = p
+		} else {
+			p.Count -= oMin
+			p.Error -= oMin
+		}
+		p.ScreenName = op.ScreenName
+		p.Count += op.Count
+		p.Error += op.Error
+		p.Breakdown.Merge(&op.Breakdown)
+		for term, b := range op.Terms {
+			breakdownFor(p.Terms, term).Merge(b)
+		}
+	}
+
+	profiles := make([]*UserProfile, 0, len(merged))
+	for _, p := range merged {
+		profiles = append(profiles, p)
+	}
+	u.set(profiles)
+}
+
+// set replaces the profiles with the k with the highest counts.
+func (u *UserProfiles) set(profiles []*UserProfile) {
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].Count > profiles[j].Count })
+	if len(profiles) > u.k {
+		profiles = profiles[:u.k]
+	}
+	u.users = make(map[string]*UserProfile, len(profiles))
+	u.heap = make(profileHeap, 0, len(profiles))
+	for _, p := range profiles {
+		if p.Terms == nil {
+			p.Terms = make(map[string]*Breakdown)
+		}
+		u.users[p.ID] = p
+		p.index = len(u.heap)
+		u.heap = append(u.heap, p)
+	}
+	heap.Init(&u.heap)
+}
+
+// minCount returns the lowest count if u is full, and 0 if it isn't, as
+// then no user has been evicted.
+func (u *UserProfiles) minCount() int {
+	if len(u.heap) < u.k {
+		return 0
+	}
+	return u.heap[0].Count
+}
+
+// Profiles returns copies of the profiles, in no particular order.
+func (u *UserProfiles) Profiles() []UserProfile {
+	profiles := make([]UserProfile, 0, len(u.users))
+	for _, p := range u.users {
+		profiles = append(profiles, *p)
+	}
+	return profiles
+}
+
+// profilePointers returns the profiles themselves, for saving.
+func (u *UserProfiles) profilePointers() []*UserProfile {
+	profiles := make([]*UserProfile, 0, len(u.users))
+	for _, p := range u.users {
+		profiles = append(profiles, p)
+	}
+	return profiles
+}
+
+// MostActive returns the n users with the highest counts.
+func (u *UserProfiles) MostActive(n int) []UserProfile {
+	profiles := u.Profiles()
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Count != profiles[j].Count {
+			return profiles[i].Count > profiles[j].Count
+		}
+		return profiles[i].ID < profiles[j].ID
+	})
+	return firstProfiles(profiles, n)
+}
+
+// MostNegative returns the n users with the lowest average sentiment, out
+// of those we have seen enough tweets from.
+func (u *UserProfiles) MostNegative(n int) []UserProfile {
+	profiles := u.rankable()
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].SentimentAverage < profiles[j].SentimentAverage })
+	return firstProfiles(profiles, n)
+}
+
+// MostPositive returns the n users with the highest average sentiment, out
+// of those we have seen enough tweets from.
+func (u *UserProfiles) MostPositive(n int) []UserProfile {
+	profiles := u.rankable()
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].SentimentAverage > profiles[j].SentimentAverage })
+	return firstProfiles(profiles, n)
+}
+
+// rankable returns the profiles with at least minProfileTweets tweets.
+func (u *UserProfiles) rankable() []UserProfile {
+	var profiles []UserProfile
+	for _, p := range u.users {
+		if p.Counts["total"] >= minProfileTweets {
+			profiles = append(profiles, *p)
+		}
+	}
+	return profiles
+}
+
+// firstProfiles returns up to the first n profiles.
+func firstProfiles(profiles []UserProfile, n int) []UserProfile {
+	if len(profiles) > n {
+		return profiles[:n]
+	}
+	return profiles
+}
+
+// profileHeap is a min-heap of profiles by count.
+type profileHeap []*UserProfile
+
+func (h profileHeap) Len() int           { return len(h) }
+func (h profileHeap) Less(i, j int) bool { return h[i].Count < h[j].Count }
+
+func (h profileHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *profileHeap) Push(x interface{}) {
+	p := x.(*UserProfile)
+	p.index = len(*h)
+	*h = append(*h, p)
+}
+
+func (h *profileHeap) Pop() interface{} {
+	old := *h
+	p := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return p
+}
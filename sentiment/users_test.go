@@ -0,0 +1,103 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestUserProfilesMerge(t *testing.T) {
+
+	// profileCount is a profile's Count, Error and the number of tweets in
+	// its breakdown.
+	type profileCount struct {
+		count, err, tweets int
+	}
+	tests := []struct {
+		name string
+		k    int
+		u, o []string
+		want map[string]profileCount
+	}{
+		{
+			name: "disjoint",
+			k:    3,
+			u:    []string{"a", "a"},
+			o:    []string{"b"},
+			want: map[string]profileCount{"a": {2, 0, 2}, "b": {1, 0, 1}},
+		},
+		{
+			name: "overlapping",
+			k:    3,
+			u:    []string{"a", "b"},
+			o:    []string{"a", "a"},
+			want: map[string]profileCount{"a": {3, 0, 3}, "b": {1, 0, 1}},
+		},
+		{
+			name: "keeps the top k",
+			k:    3,
+			u:    []string{"a", "a", "a", "b"},
+			o:    []string{"c", "c", "d", "d", "d"},
+			want: map[string]profileCount{"a": {3, 0, 3}, "c": {2, 0, 2}, "d": {3, 0, 3}},
+		},
+		{
+			// When both are full, a user missing from one of them may have
+			// had up to its smallest count there, which is added as error.
+			name: "full summaries",
+			k:    2,
+			u:    []string{"a", "a", "a", "b"},
+			o:    []string{"a", "c", "c"},
+			want: map[string]profileCount{"a": {4, 0, 4}, "c": {3, 1, 2}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, o := NewUserProfiles(tt.k), NewUserProfiles(tt.k)
+			addUsers(u, tt.u)
+			addUsers(o, tt.o)
+			u.Merge(o)
+
+			got := make(map[string]profileCount)
+			for _, p := range u.Profiles() {
+				got[p.ID] = profileCount{p.Count, p.Error, p.Counts["total"]}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+
+			// The merged profiles must still work as a summary.
+			u.Add(userTweet("d"))
+		})
+	}
+}
+
+func TestUserProfilesAdd(t *testing.T) {
+	u := NewUserProfiles(2)
+	addUsers(u, []string{"a", "a", "b", "c"})
+
+	// c takes over from b, the least active user, and inherits its count
+	// as error.
+	got := make(map[string][2]int)
+	for _, p := range u.Profiles() {
+		got[p.ID] = [2]int{p.Count, p.Error}
+	}
+	want := map[string][2]int{"a": {2, 0}, "c": {2, 1}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func addUsers(u *UserProfiles, ids []string) {
+	for _, id := range ids {
+		u.Add(userTweet(id))
+	}
+}
+
+func userTweet(id string) ScoredTweet {
+	var t ScoredTweet
+	t.User.ID = id
+	t.User.ScreenName = "user" + id
+	t.Sentiment = 0.8
+	t.Weight = 1
+	t.Influence = 1
+	return t
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// The stream keeps an hour of windowed stats in one-minute buckets.
+const (
+	windowBucket  = time.Minute
+	windowBuckets = 60
+)
+
+// WindowedStats keeps the sentiment of recent tweets in fixed-size time
+// buckets, so we can report on the last few minutes rather than on
+// everything since we started. It is safe for concurrent use.
+type WindowedStats struct {
+	bucket  time.Duration
+	mux     sync.Mutex
+	buckets []*statsBucket
+}
+
+// statsBucket holds the sentiment of the tweets received in a bucket.
+type statsBucket struct {
+	start time.Time
+	total *Breakdown
+	terms map[string]*Breakdown
+}
+
+// WindowSnapshot is the sentiment of the tweets received in a window.
+type WindowSnapshot struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+	Breakdown
+	Terms map[string]*Breakdown `json:"terms,omitempty"`
+}
+
+// NewWindowedStats creates a WindowedStats covering n buckets of the given
+// length, which is the longest window it can report on.
+func NewWindowedStats(bucket time.Duration, n int) *WindowedStats {
+	return &WindowedStats{
+		bucket:  bucket,
+		buckets: make([]*statsBucket, n),
+	}
+}
+
+// Max returns the longest window w can report on.
+func (w *WindowedStats) Max() time.Duration {
+	return w.bucket * time.Duration(len(w.buckets))
+}
+
+// Record adds a scored tweet received at now.
+func (w *WindowedStats) Record(t ScoredTweet, now time.Time) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	// Reuse the bucket's slot once it has fallen out of every window.
+	start := now.Truncate(w.bucket)
+	i := int(start.UnixNano()/int64(w.bucket)) % len(w.buckets)
+	b := w.buckets[i]
+	if b == nil || !b.start.Equal(start) {
+		b = &statsBucket{
+			start: start,
+			total: NewBreakdown(),
+			terms: make(map[string]*Breakdown),
+		}
+		w.buckets[i] = b
+	}
+
+	b.total.Add(t.Sentiment, t.Weight, t.Influence)
+	for _, a := range t.Aspects {
+		if a.Kind == aspectTerm {
+			breakdownFor(b.terms, a.Name).Add(a.Sentiment, t.Weight, t.Influence)
+		}
+	}
+}
+
+// Window returns the sentiment of the tweets received in the window of
+// length d up to now. The window is rounded up to whole buckets, and cut
+// down to Max.
+func (w *WindowedStats) Window(d time.Duration, now time.Time) WindowSnapshot {
+	if d > w.Max() {
+		d = w.Max()
+	}
+	end := now.Truncate(w.bucket).Add(w.bucket)
+	snapshot := WindowSnapshot{
+		Start:     end.Add(-d).Truncate(w.bucket),
+		End:       end,
+		Breakdown: *NewBreakdown(),
+		Terms:     make(map[string]*Breakdown),
+	}
+
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for _, b := range w.buckets {
+		if b == nil || b.start.Before(snapshot.Start) || !b.start.Before(snapshot.End) {
+			continue
+		}
+		snapshot.Breakdown.Merge(b.total)
+		for term, tb := range b.terms {
+			breakdownFor(snapshot.Terms, term).Merge(tb)
+		}
+	}
+	return snapshot
+}
+
+// file returns a copy of the buckets in their JSON form.
+func (w *WindowedStats) file() []windowBucketFile {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	var buckets []windowBucketFile
+	for _, b := range w.buckets {
+		if b == nil {
+			continue
+		}
+		bf := windowBucketFile{
+			Start: b.start,
+			Total: NewBreakdown(),
+			Terms: make(map[string]*Breakdown),
+		}
+		bf.Total.Merge(b.total)
+		for term, tb := range b.terms {
+			breakdownFor(bf.Terms, term).Merge(tb)
+		}
+		buckets = append(buckets, bf)
+	}
+	sort.Slice(buckets, func(i, j int) bool { return buckets[i].Start.Before(buckets[j].Start) })
+	return buckets
+}
+
+// restore puts saved buckets back. Buckets that don't line up with w's
+// bucket length are dropped, as are those older than a bucket already in
+// their slot.
+func (w *WindowedStats) restore(buckets []windowBucketFile) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	for _, bf := range buckets {
+		if bf.Total == nil || !bf.Start.Equal(bf.Start.Truncate(w.bucket)) {
+			continue
+		}
+		i := int(bf.Start.UnixNano()/int64(w.bucket)) % len(w.buckets)
+		if b := w.buckets[i]; b != nil && !b.start.Before(bf.Start) {
+			continue
+		}
+		b := &statsBucket{
+			start: bf.Start,
+			total: NewBreakdown(),
+			terms: make(map[string]*Breakdown),
+		}
+		b.total.Merge(bf.Total)
+		for term, tb := range bf.Terms {
+			breakdownFor(b.terms, term).Merge(tb)
+		}
+		w.buckets[i] = b
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWindowedStats(t *testing.T) {
+	start := time.Date(2018, 3, 7, 12, 0, 0, 0, time.UTC)
+	w := NewWindowedStats(time.Minute, 5)
+	tweet := func(sentiment float64) ScoredTweet {
+		var st ScoredTweet
+		st.Sentiment = sentiment
+		st.Weight = 1
+		st.Influence = 1
+		st.Aspects = []Aspect{{Name: "Trump", Kind: aspectTerm, Sentiment: sentiment}}
+		return st
+	}
+
+	// Five tweets over four minutes, the last two in the same minute.
+	for i, at := range []time.Duration{0, time.Minute, 2 * time.Minute, 3*time.Minute + 10*time.Second, 3*time.Minute + 50*time.Second} {
+		w.Record(tweet(0.1*float64(i+1)), start.Add(at))
+	}
+	now := start.Add(3*time.Minute + 55*time.Second)
+
+	tests := []struct {
+		name      string
+		d         time.Duration
+		now       time.Time
+		wantStart time.Time
+		wantTotal int
+	}{
+		{"this minute", time.Minute, now, start.Add(3 * time.Minute), 2},
+		{"part of a minute is rounded up", 90 * time.Second, now, start.Add(2 * time.Minute), 3},
+		{"everything", 5 * time.Minute, now, start.Add(-time.Minute), 5},
+		{"cut down to the max", time.Hour, now, start.Add(-time.Minute), 5},
+		{"old buckets fall out", 5 * time.Minute, start.Add(6 * time.Minute), start.Add(2 * time.Minute), 3},
+		{"all buckets fall out", 5 * time.Minute, start.Add(10 * time.Minute), start.Add(6 * time.Minute), 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := w.Window(tt.d, tt.now)
+			if !got.Start.Equal(tt.wantStart) {
+				t.Errorf("got window from %v, want %v", got.Start, tt.wantStart)
+			}
+			if !got.End.Equal(tt.now.Truncate(time.Minute).Add(time.Minute)) {
+				t.Errorf("got window to %v, want the end of the minute", got.End)
+			}
+			if got.Counts["total"] != tt.wantTotal {
+				t.Errorf("got %d tweets, want %d", got.Counts["total"], tt.wantTotal)
+			}
+			if tt.wantTotal > 0 && got.Terms["Trump"].Counts["total"] != tt.wantTotal {
+				t.Errorf("got %d tweets about Trump, want %d", got.Terms["Trump"].Counts["total"], tt.wantTotal)
+			}
+		})
+	}
+}
+
+func TestWindowedStatsReuse(t *testing.T) {
+	start := time.Date(2018, 3, 7, 12, 0, 0, 0, time.UTC)
+	w := NewWindowedStats(time.Minute, 5)
+	var st ScoredTweet
+	st.Sentiment = 0.9
+	st.Weight = 1
+	st.Influence = 1
+	w.Record(st, start)
+
+	// Five minutes later the first minute's slot is reused, so its tweet is
+	// gone even from a window that would reach back to it.
+	later := start.Add(w.Max())
+	w.Record(st, later)
+	if got := len(w.file()); got != 1 {
+		t.Errorf("got %d buckets, want the first reused", got)
+	}
+	got := w.Window(w.Max(), later)
+	if got.Counts["total"] != 1 {
+		t.Errorf("got %d tweets, want only the latest", got.Counts["total"])
+	}
+	if got.SentimentAverage != 0.9 {
+		t.Errorf("got average %v, want 0.9", got.SentimentAverage)
+	}
+}